@@ -1,6 +1,7 @@
 package bc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 
@@ -21,27 +22,42 @@ type Block struct {
 	Txs         []*bt.Tx
 }
 
-// String returns the Block Header encoded as hex string.
+// String returns the Block encoded as hex string, or an empty string if its
+// header is malformed.
 func (b *Block) String() string {
-	return hex.EncodeToString(b.Bytes())
+	bytes, err := b.Bytes()
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(bytes)
 }
 
-// Bytes will decode a bitcoin block struct into a byte slice.
+// Bytes will decode a bitcoin block struct into a byte slice. ErrMalformedHeader
+// is returned if the fields of its header have the wrong length.
 //
 // See https://btcinformation.org/en/developer-reference#serialized-blocks
-func (b *Block) Bytes() []byte {
-	bytes := []byte{}
-
-	bytes = append(bytes, b.BlockHeader.Bytes()...)
+func (b *Block) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		return nil, err
+	}
 
-	txCount := uint64(len(b.Txs))
-	bytes = append(bytes, bt.VarInt(txCount).Bytes()...)
+	return buf.Bytes(), nil
+}
 
-	for _, tx := range b.Txs {
-		bytes = append(bytes, tx.Bytes()...)
-	}
+// TxIDs returns the txids of the transactions of the block in wire order, hashing
+// them across multiple goroutines for large blocks. Pass them to BuildTypedMerkleRoot
+// to calculate the merkle root of the block.
+func (b *Block) TxIDs() []Hash {
+	txIDs := make([]Hash, len(b.Txs))
+	parallelFor(len(b.Txs), func(start, end int) {
+		for i := start; i < end; i++ {
+			txIDs[i] = Sha256dHash(b.Txs[i].Bytes())
+		}
+	})
 
-	return bytes
+	return txIDs
 }
 
 // NewBlockFromStr will encode a block header hash
@@ -58,7 +74,8 @@ func NewBlockFromStr(blockStr string) (*Block, error) {
 }
 
 // NewBlockFromBytes will encode a block header byte slice
-// into the bitcoin block header structure.
+// into the bitcoin block header structure. ErrBlockTruncated is
+// returned if the block ends before all of its transactions.
 //
 // See https://btcinformation.org/en/developer-reference#serialized-blocks
 func NewBlockFromBytes(b []byte) (*Block, error) {
@@ -66,28 +83,10 @@ func NewBlockFromBytes(b []byte) (*Block, error) {
 		return nil, errors.New("block cannot be empty")
 	}
 
-	var offset int
-	bh, err := NewBlockHeaderFromBytes(b[:80])
-	if err != nil {
+	var block Block
+	if _, err := block.ReadFrom(bytes.NewReader(b)); err != nil {
 		return nil, err
 	}
-	offset += 80
-
-	txCount, size := bt.NewVarIntFromBytes(b[offset:])
-	offset += size
-
-	var txs []*bt.Tx
-	for i := 0; i < int(txCount); i++ {
-		tx, size, err := bt.NewTxFromStream(b[offset:])
-		if err != nil {
-			return nil, err
-		}
-		txs = append(txs, tx)
-		offset += size
-	}
 
-	return &Block{
-		BlockHeader: bh,
-		Txs:         txs,
-	}, nil
+	return &block, nil
 }
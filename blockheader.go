@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/libsv/go-bk/crypto"
@@ -56,17 +57,34 @@ func (bh *BlockHeader) BitsStr() string {
 	return hex.EncodeToString(bh.Bits)
 }
 
-// String returns the Block Header encoded as hex string.
+// String returns the Block Header encoded as hex string, or an empty string if
+// its fields have the wrong length.
 func (bh *BlockHeader) String() string {
-	return hex.EncodeToString(bh.Bytes())
+	b, err := bh.Bytes()
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
 }
 
 // Bytes will decode a bitcoin block header struct
-// into a byte slice.
+// into a byte slice. ErrMalformedHeader is returned if HashPrevBlock or
+// HashMerkleRoot aren't 32 bytes or Bits isn't 4 bytes.
 //
 // See https://en.bitcoin.it/wiki/Block_hashing_algorithm
-func (bh *BlockHeader) Bytes() []byte {
-	bytes := []byte{}
+func (bh *BlockHeader) Bytes() ([]byte, error) {
+	if err := checkHeaderFields(bh); err != nil {
+		return nil, err
+	}
+
+	return bh.bytes(), nil
+}
+
+// bytes serialises the header without checking the length of its fields, which the
+// caller must already have done.
+func (bh *BlockHeader) bytes() []byte {
+	bytes := make([]byte, 0, 80)
 	bytes = append(bytes, UInt32ToBytes(bh.Version)...)
 	bytes = append(bytes, bt.ReverseBytes(bh.HashPrevBlock)...)
 	bytes = append(bytes, bt.ReverseBytes(bh.HashMerkleRoot)...)
@@ -76,22 +94,90 @@ func (bh *BlockHeader) Bytes() []byte {
 	return bytes
 }
 
+// Hash returns the block hash of the header. ErrMalformedHeader is returned if its
+// fields have the wrong length.
+func (bh *BlockHeader) Hash() (Hash, error) {
+	if err := checkHeaderFields(bh); err != nil {
+		return Hash{}, err
+	}
+
+	return Sha256dHash(bh.bytes()), nil
+}
+
+// hash returns the block hash of the header in display (big endian) order, the
+// caller must already have checked the length of its fields.
+func (bh *BlockHeader) hash() []byte {
+	return bt.ReverseBytes(crypto.Sha256d(bh.bytes()))
+}
+
+// clone returns a deep copy of the header so that it can be stored without
+// being affected by later changes to the original.
+func (bh *BlockHeader) clone() *BlockHeader {
+	return &BlockHeader{
+		Version:        bh.Version,
+		Time:           bh.Time,
+		Nonce:          bh.Nonce,
+		HashPrevBlock:  append([]byte{}, bh.HashPrevBlock...),
+		HashMerkleRoot: append([]byte{}, bh.HashMerkleRoot...),
+		Bits:           append([]byte{}, bh.Bits...),
+	}
+}
+
+// checkHeaderFields ensures the fixed size fields of a header have the correct
+// length before it is serialised or hashed.
+func checkHeaderFields(bh *BlockHeader) error {
+	switch {
+	case bh == nil:
+		return fmt.Errorf("%w: header is nil", ErrMalformedHeader)
+	case len(bh.HashPrevBlock) != 32:
+		return fmt.Errorf("%w: hashPrevBlock should be 32 bytes", ErrMalformedHeader)
+	case len(bh.HashMerkleRoot) != 32:
+		return fmt.Errorf("%w: merkleRoot should be 32 bytes", ErrMalformedHeader)
+	case len(bh.Bits) != 4:
+		return fmt.Errorf("%w: bits should be 4 bytes", ErrMalformedHeader)
+	}
+
+	return nil
+}
+
 // Valid checks whether a blockheader satisfies the proof-of-work claimed
 // in Bits. Wwe check whether its Hash256 read as a little endian number
 // is less than the Bits written in expanded form.
 func (bh *BlockHeader) Valid() bool {
+	if checkHeaderFields(bh) != nil {
+		return false
+	}
+
 	target, err := ExpandTargetFromAsInt(hex.EncodeToString(bh.Bits))
 	if err != nil {
 		return false
 	}
 
-	digest := bt.ReverseBytes(crypto.Sha256d(bh.Bytes()))
 	var bn = big.NewInt(0)
-	bn.SetBytes(digest)
+	bn.SetBytes(bh.hash())
 
 	return bn.Cmp(target) < 0
 }
 
+// NewBlockHeader returns a BlockHeader built from its fields, the hashes and bits being
+// in display (big endian) order as they are held in a BlockHeader. ErrMalformedHeader is
+// returned if prevBlock or merkleRoot aren't 32 bytes or bits isn't 4 bytes.
+func NewBlockHeader(version uint32, prevBlock, merkleRoot []byte, time uint32, bits []byte, nonce uint32) (*BlockHeader, error) {
+	bh := &BlockHeader{
+		Version:        version,
+		Time:           time,
+		Nonce:          nonce,
+		HashPrevBlock:  prevBlock,
+		HashMerkleRoot: merkleRoot,
+		Bits:           bits,
+	}
+	if err := checkHeaderFields(bh); err != nil {
+		return nil, err
+	}
+
+	return bh.clone(), nil
+}
+
 // NewBlockHeaderFromStr will encode a block header hash
 // into the bitcoin block header structure.
 //
@@ -139,7 +225,12 @@ func ExtractMerkleRootFromBlockHeader(header string) (string, error) {
 }
 
 // MarshalJSON marshals the receiving bc.BlockHeader into a JSON []byte.
+// ErrMalformedHeader is returned if its fields have the wrong length.
 func (bh *BlockHeader) MarshalJSON() ([]byte, error) {
+	if err := checkHeaderFields(bh); err != nil {
+		return nil, err
+	}
+
 	return json.Marshal(bhJSON{
 		Version:        bh.Version,
 		Time:           bh.Time,
@@ -151,33 +242,153 @@ func (bh *BlockHeader) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON unmarshals a JSON []byte into the receiving bc.BlockHeader.
+// ErrMalformedHeader is returned if the hashes aren't 32 bytes or the bits
+// aren't 4 bytes.
 func (bh *BlockHeader) UnmarshalJSON(b []byte) error {
 	var bhj bhJSON
 	if err := json.Unmarshal(b, &bhj); err != nil {
 		return err
 	}
 
-	bh.Version = bhj.Version
-	bh.Nonce = bhj.Nonce
-	bh.Time = bhj.Time
-
 	bits, err := hex.DecodeString(bhj.Bits)
 	if err != nil {
 		return err
 	}
-	bh.Bits = bits
 
 	hashPrevBlock, err := hex.DecodeString(bhj.HashPrevBlock)
 	if err != nil {
 		return err
 	}
-	bh.HashPrevBlock = hashPrevBlock
 
 	hashMerkleRoot, err := hex.DecodeString(bhj.HashMerkleRoot)
 	if err != nil {
 		return err
 	}
-	bh.HashMerkleRoot = hashMerkleRoot
+
+	header, err := NewBlockHeader(bhj.Version, hashPrevBlock, hashMerkleRoot, bhj.Time, bits, bhj.Nonce)
+	if err != nil {
+		return err
+	}
+	*bh = *header
+
+	return nil
+}
+
+// A TypedBlockHeader is a BlockHeader whose hashes are held as Hash and bits as a
+// number, so that its fields always have the correct length and byte order.
+type TypedBlockHeader struct {
+	Version    uint32
+	PrevBlock  Hash
+	MerkleRoot Hash
+	Time       uint32
+	Bits       uint32
+	Nonce      uint32
+}
+
+// NewTypedBlockHeaderFromBytes decodes an 80 byte serialised block header.
+func NewTypedBlockHeaderFromBytes(headerBytes []byte) (*TypedBlockHeader, error) {
+	if len(headerBytes) != 80 {
+		return nil, errors.New("block header should be 80 bytes long")
+	}
+
+	bh := &TypedBlockHeader{
+		Version: binary.LittleEndian.Uint32(headerBytes[:4]),
+		Time:    binary.LittleEndian.Uint32(headerBytes[68:72]),
+		Bits:    binary.LittleEndian.Uint32(headerBytes[72:76]),
+		Nonce:   binary.LittleEndian.Uint32(headerBytes[76:]),
+	}
+	copy(bh.PrevBlock[:], headerBytes[4:36])
+	copy(bh.MerkleRoot[:], headerBytes[36:68])
+
+	return bh, nil
+}
+
+// Typed returns the header as a TypedBlockHeader, or ErrMalformedHeader if its fields
+// have the wrong length.
+func (bh *BlockHeader) Typed() (*TypedBlockHeader, error) {
+	if err := checkHeaderFields(bh); err != nil {
+		return nil, err
+	}
+
+	prev, _ := NewHashFromDisplayBytes(bh.HashPrevBlock)
+	root, _ := NewHashFromDisplayBytes(bh.HashMerkleRoot)
+
+	return &TypedBlockHeader{
+		Version:    bh.Version,
+		PrevBlock:  prev,
+		MerkleRoot: root,
+		Time:       bh.Time,
+		Bits:       binary.BigEndian.Uint32(bh.Bits),
+		Nonce:      bh.Nonce,
+	}, nil
+}
+
+// BlockHeader returns the header as a BlockHeader.
+func (bh *TypedBlockHeader) BlockHeader() *BlockHeader {
+	bits := make([]byte, 4)
+	binary.BigEndian.PutUint32(bits, bh.Bits)
+
+	return &BlockHeader{
+		Version:        bh.Version,
+		Time:           bh.Time,
+		Nonce:          bh.Nonce,
+		HashPrevBlock:  bh.PrevBlock.DisplayBytes(),
+		HashMerkleRoot: bh.MerkleRoot.DisplayBytes(),
+		Bits:           bits,
+	}
+}
+
+// Bytes returns the 80 byte serialised header.
+func (bh *TypedBlockHeader) Bytes() []byte {
+	b := make([]byte, 80)
+	binary.LittleEndian.PutUint32(b[:4], bh.Version)
+	copy(b[4:36], bh.PrevBlock[:])
+	copy(b[36:68], bh.MerkleRoot[:])
+	binary.LittleEndian.PutUint32(b[68:72], bh.Time)
+	binary.LittleEndian.PutUint32(b[72:76], bh.Bits)
+	binary.LittleEndian.PutUint32(b[76:], bh.Nonce)
+
+	return b
+}
+
+// String returns the serialised header encoded as hex string.
+func (bh *TypedBlockHeader) String() string {
+	return hex.EncodeToString(bh.Bytes())
+}
+
+// Hash returns the block hash of the header.
+func (bh *TypedBlockHeader) Hash() Hash {
+	return Sha256dHash(bh.Bytes())
+}
+
+// Valid checks whether the header satisfies the proof-of-work claimed in Bits.
+func (bh *TypedBlockHeader) Valid() bool {
+	target := CompactToBig(bh.Bits)
+	if target.Sign() <= 0 {
+		return false
+	}
+
+	hash := bh.Hash()
+	return new(big.Int).SetBytes(hash.DisplayBytes()).Cmp(target) < 0
+}
+
+// MarshalJSON marshals the header into the same JSON as a BlockHeader.
+func (bh *TypedBlockHeader) MarshalJSON() ([]byte, error) {
+	return bh.BlockHeader().MarshalJSON()
+}
+
+// UnmarshalJSON unmarshals the JSON of a BlockHeader into the receiving header.
+func (bh *TypedBlockHeader) UnmarshalJSON(b []byte) error {
+	var h BlockHeader
+	if err := h.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	typed, err := h.Typed()
+	if err != nil {
+		return err
+	}
+	*bh = *typed
 
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -65,7 +66,9 @@ func TestBlockHeaderStringAndBytesMatch(t *testing.T) {
 	headerStr := "0000002074a17794e7890e9124d87e122b7f67b9d707dcb6c5b9d542b22eff3d13054678e9d8afa92026c2c0873524b18cbf2479720a8471952770c847d9ec8e1e939dfc1f593460ffff7f2000000000"
 	bh, err := bc.NewBlockHeaderFromStr(headerStr)
 	assert.NoError(t, err)
-	assert.Equal(t, hex.EncodeToString(bh.Bytes()), bh.String())
+	b, err := bh.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(b), bh.String())
 }
 
 func TestBlockHeaderInvalid(t *testing.T) {
@@ -137,6 +140,7 @@ func TestBlockHeader_MarshalJSON(t *testing.T) {
 	tests := map[string]struct {
 		bh      *bc.BlockHeader
 		expJSON string
+		expErr  error
 	}{
 		"can be marshalled": {
 			bh: func() *bc.BlockHeader {
@@ -166,26 +170,31 @@ func TestBlockHeader_MarshalJSON(t *testing.T) {
 	"bits": "207fffff"
 }`,
 		},
-		"nil data doesn't error": {
+		"nil data errors": {
 			bh: &bc.BlockHeader{
 				Version: 0,
 				Time:    0,
 				Nonce:   0,
 			},
-			expJSON: `{
-	"version": 0,
-	"time": 0,
-	"nonce": 0,
-	"hashPrevBlock": "",
-	"merkleRoot": "",
-	"bits": ""
-}`,
+			expErr: bc.ErrMalformedHeader,
+		},
+		"short bits errors": {
+			bh: &bc.BlockHeader{
+				HashPrevBlock:  make([]byte, 32),
+				HashMerkleRoot: make([]byte, 32),
+				Bits:           []byte{0x7f, 0xff, 0xff},
+			},
+			expErr: bc.ErrMalformedHeader,
 		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			bhj, err := json.MarshalIndent(test.bh, "", "\t")
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr))
+				return
+			}
 			assert.NoError(t, err)
 			assert.Equal(t, test.expJSON, string(bhj))
 		})
@@ -196,21 +205,20 @@ func TestBlockHeader_UnmarshalJSON(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		bh  *bc.BlockHeader
-		err error
+		json   string
+		expBh  *bc.BlockHeader
+		expErr error
 	}{
-		"valid zero data can be unmarshalled": {
-			bh: &bc.BlockHeader{
-				Version:        0,
-				Nonce:          0,
-				Time:           0,
-				Bits:           []byte{},
-				HashMerkleRoot: []byte{},
-				HashPrevBlock:  []byte{},
-			},
-		},
 		"valid data can be unmarshalled": {
-			bh: &bc.BlockHeader{
+			json: `{
+	"version": 536870912,
+	"time": 1630935071,
+	"nonce": 1630935071,
+	"hashPrevBlock": "6164ce1e9892020cb8e6da84157f05c98b97f3293ad6650eb1d7344f65961eb2",
+	"merkleRoot": "59a4b0d7e39a1414bd0db3d27a9e967388433eeb951124ec5a8bfb5232f96afd",
+	"bits": "207fffff"
+}`,
+			expBh: &bc.BlockHeader{
 				Version:        536870912,
 				Nonce:          1630935071,
 				Time:           1630935071,
@@ -219,28 +227,114 @@ func TestBlockHeader_UnmarshalJSON(t *testing.T) {
 				HashPrevBlock:  []byte{0x61, 0x64, 0xce, 0x1e, 0x98, 0x92, 0x02, 0x0c, 0xb8, 0xe6, 0xda, 0x84, 0x15, 0x7f, 0x05, 0xc9, 0x8b, 0x97, 0xf3, 0x29, 0x3a, 0xd6, 0x65, 0x0e, 0xb1, 0xd7, 0x34, 0x4f, 0x65, 0x96, 0x1e, 0xb2},
 			},
 		},
-		"nil data does not error": {
-			bh: &bc.BlockHeader{
-				Version: 536870912,
-				Nonce:   1630935071,
-				Time:    1630935071,
-			},
+		"empty data errors": {
+			json:   `{"version": 536870912, "time": 1630935071, "nonce": 1630935071}`,
+			expErr: bc.ErrMalformedHeader,
+		},
+		"short hashPrevBlock errors": {
+			json: `{
+	"hashPrevBlock": "64ce1e9892020cb8e6da84157f05c98b97f3293ad6650eb1d7344f65961eb2",
+	"merkleRoot": "59a4b0d7e39a1414bd0db3d27a9e967388433eeb951124ec5a8bfb5232f96afd",
+	"bits": "207fffff"
+}`,
+			expErr: bc.ErrMalformedHeader,
+		},
+		"long merkleRoot errors": {
+			json: `{
+	"hashPrevBlock": "6164ce1e9892020cb8e6da84157f05c98b97f3293ad6650eb1d7344f65961eb2",
+	"merkleRoot": "59a4b0d7e39a1414bd0db3d27a9e967388433eeb951124ec5a8bfb5232f96afd00",
+	"bits": "207fffff"
+}`,
+			expErr: bc.ErrMalformedHeader,
+		},
+		"long bits errors": {
+			json: `{
+	"hashPrevBlock": "6164ce1e9892020cb8e6da84157f05c98b97f3293ad6650eb1d7344f65961eb2",
+	"merkleRoot": "59a4b0d7e39a1414bd0db3d27a9e967388433eeb951124ec5a8bfb5232f96afd",
+	"bits": "207fffff00"
+}`,
+			expErr: bc.ErrMalformedHeader,
 		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			b, err := json.Marshal(test.bh)
-			if test.err != nil {
-				assert.Error(t, err)
-				assert.EqualError(t, err, test.err.Error())
+			var bh *bc.BlockHeader
+			err := json.Unmarshal([]byte(test.json), &bh)
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr))
 				return
 			}
 			assert.NoError(t, err)
-
-			var bh *bc.BlockHeader
-			assert.NoError(t, json.Unmarshal(b, &bh))
-			assert.Equal(t, test.bh.String(), bh.String())
+			assert.Equal(t, test.expBh, bh)
 		})
 	}
 }
+
+func TestNewBlockHeader_Validates(t *testing.T) {
+	t.Parallel()
+
+	bh, err := bc.NewBlockHeader(1, make([]byte, 32), make([]byte, 32), 2, []byte{0x20, 0x7f, 0xff, 0xff}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "01000000"+strings.Repeat("00", 64)+"02000000ffff7f2003000000", bh.String())
+
+	_, err = bc.NewBlockHeader(1, make([]byte, 31), make([]byte, 32), 2, []byte{0x20, 0x7f, 0xff, 0xff}, 3)
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+	_, err = bc.NewBlockHeader(1, make([]byte, 32), nil, 2, []byte{0x20, 0x7f, 0xff, 0xff}, 3)
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+	_, err = bc.NewBlockHeader(1, make([]byte, 32), make([]byte, 32), 2, []byte{0xff, 0xff}, 3)
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+}
+
+func TestBlockHeader_Hash(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bc.MainNetParams.Genesis.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", hash.String())
+
+	bh := &bc.BlockHeader{HashPrevBlock: make([]byte, 32), HashMerkleRoot: make([]byte, 32), Bits: []byte{0x1d}}
+	_, err = bh.Hash()
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+	_, err = bh.Bytes()
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+	assert.Equal(t, "", bh.String())
+	assert.False(t, bh.Valid())
+}
+
+func TestTypedBlockHeader(t *testing.T) {
+	t.Parallel()
+
+	const headerStr = "0000002074a17794e7890e9124d87e122b7f67b9d707dcb6c5b9d542b22eff3d13054678e9d8afa92026c2c0873524b18cbf2479720a8471952770c847d9ec8e1e939dfc1f593460ffff7f2000000000"
+	bh, err := bc.NewBlockHeaderFromStr(headerStr)
+	assert.NoError(t, err)
+
+	headerBytes, _ := hex.DecodeString(headerStr)
+	tbh, err := bc.NewTypedBlockHeaderFromBytes(headerBytes)
+	assert.NoError(t, err)
+
+	typed, err := bh.Typed()
+	assert.NoError(t, err)
+	assert.Equal(t, tbh, typed)
+
+	assert.Equal(t, headerStr, tbh.String())
+	assert.Equal(t, bh, tbh.BlockHeader())
+	assert.Equal(t, "784605133dff2eb242d5b9c5b6dc07d7b9677f2b127ed824910e89e79477a174", tbh.PrevBlock.String())
+	assert.Equal(t, "fc9d931e8eecd947c870279571840a727924bf8cb1243587c0c22620a9afd8e9", tbh.MerkleRoot.String())
+	assert.Equal(t, uint32(0x207fffff), tbh.Bits)
+	assert.Equal(t, bc.Sha256dHash(headerBytes), tbh.Hash())
+	assert.Equal(t, bh.Valid(), tbh.Valid())
+
+	b, err := json.Marshal(tbh)
+	assert.NoError(t, err)
+	expJSON, err := json.Marshal(bh)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expJSON), string(b))
+
+	var unmarshalled bc.TypedBlockHeader
+	assert.NoError(t, json.Unmarshal(b, &unmarshalled))
+	assert.Equal(t, tbh, &unmarshalled)
+
+	_, err = (&bc.BlockHeader{Bits: []byte{1}}).Typed()
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+}
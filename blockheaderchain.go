@@ -3,6 +3,7 @@ package bc
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,6 +11,12 @@ var (
 	ErrHeaderNotFound = errors.New("header with not found")
 	// ErrNotOnLongestChain indicates the blockhash is present but isn't on the longest current chain.
 	ErrNotOnLongestChain = errors.New("header exists but is not on the longest chain")
+	// ErrOrphanHeader is returned when a header is added whose previous block isn't known.
+	ErrOrphanHeader = errors.New("header does not connect to a known header")
+	// ErrInvalidProofOfWork is returned when a header's hash doesn't satisfy the target in its bits.
+	ErrInvalidProofOfWork = errors.New("header hash is higher than its target")
+	// ErrMalformedHeader is returned when a header's fields have the wrong length.
+	ErrMalformedHeader = errors.New("header has malformed fields")
 )
 
 // A BlockHeaderChain is a generic interface used to map things in the block header chain
@@ -21,3 +28,146 @@ var (
 type BlockHeaderChain interface {
 	BlockHeader(ctx context.Context, blockHash string) (*BlockHeader, error)
 }
+
+// A MedianTimePastChain is a BlockHeaderChain which can also return the median time past
+// of a header, so that nLockTime and nSequence finality can be evaluated against it.
+type MedianTimePastChain interface {
+	BlockHeaderChain
+	MedianTimePast(ctx context.Context, blockHash string) (uint32, error)
+}
+
+// A HeaderAtHeightChain is a BlockHeaderChain which can also return the header at a
+// height of its longest chain, so that merkle paths, which identify their block by
+// height, can be verified against it.
+type HeaderAtHeightChain interface {
+	BlockHeaderChain
+	HeaderAtHeight(height uint32) (*BlockHeader, error)
+}
+
+// A BlockHeightChain is a BlockHeaderChain which can also return the height of a block
+// of its longest chain, so that the script rules a block was mined under can be found
+// from its hash.
+type BlockHeightChain interface {
+	BlockHeaderChain
+	BlockHeight(ctx context.Context, blockHash string) (uint32, error)
+}
+
+type headerChainOptions struct {
+	params *ChainParams
+	// root is the height of the first header of the chain, which is above zero when
+	// the chain starts from a trusted checkpoint header rather than genesis.
+	root uint32
+
+	difficulty        bool
+	verifyCheckpoints bool
+	checkpoints       []Checkpoint
+	time              bool
+	maxFutureDrift    time.Duration
+	now               func() time.Time
+}
+
+// HeaderChainOpt defines a functional option that is used to modify the validation
+// a block header chain applies to the headers added to it.
+type HeaderChainOpt func(opts *headerChainOptions)
+
+// VerifyDifficulty will make the header chain reject headers whose bits don't match
+// the difficulty required by the retarget rules of its ChainParams.
+func VerifyDifficulty() HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.difficulty = true
+	}
+}
+
+// NoVerifyDifficulty will switch off difficulty verification so that headers are only
+// checked against the proof of work claimed in their own bits.
+func NoVerifyDifficulty() HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.difficulty = false
+	}
+}
+
+// VerifyCheckpoints will make the header chain reject headers which conflict with a
+// checkpoint of its ChainParams, or with any of the additional checkpoints provided,
+// and headers which fork from the chain below the last checkpoint it has reached.
+func VerifyCheckpoints(checkpoints ...Checkpoint) HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.verifyCheckpoints = true
+		opts.checkpoints = append(opts.checkpoints, checkpoints...)
+	}
+}
+
+// NoVerifyCheckpoints will switch off checkpoint verification.
+func NoVerifyCheckpoints() HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.verifyCheckpoints = false
+	}
+}
+
+// VerifyTime will make the header chain reject headers whose timestamp isn't after the
+// median time past of the previous 11 headers, or is more than maxFutureDrift ahead of
+// the current time. DefaultMaxFutureBlockTime matches the drift allowed by nodes.
+func VerifyTime(maxFutureDrift time.Duration) HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.time = true
+		opts.maxFutureDrift = maxFutureDrift
+	}
+}
+
+// NoVerifyTime will switch off header timestamp verification.
+func NoVerifyTime() HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.time = false
+	}
+}
+
+// TimeSource sets the function used to read the current time when checking that
+// header timestamps aren't too far in the future, which defaults to time.Now.
+func TimeSource(now func() time.Time) HeaderChainOpt {
+	return func(opts *headerChainOptions) {
+		opts.now = now
+	}
+}
+
+// newHeaderChainOptions returns the options of a header chain for the network params
+// provided, starting at height root. Difficulty, checkpoint and time verification are
+// enabled by default.
+func newHeaderChainOptions(params *ChainParams, root uint32, opts []HeaderChainOpt) *headerChainOptions {
+	o := &headerChainOptions{
+		params:            params,
+		root:              root,
+		difficulty:        true,
+		verifyCheckpoints: true,
+		checkpoints:       append([]Checkpoint{}, params.Checkpoints...),
+		time:              true,
+		maxFutureDrift:    DefaultMaxFutureBlockTime,
+		now:               time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// validate applies the contextual checks enabled in the options to a header which
+// extends branch.
+//
+// When the chain starts from a checkpoint the headers the retarget rules need may be
+// below its root, in which case the difficulty of the header can't be checked and only
+// its proof of work against its own bits is relied upon.
+func (o *headerChainOptions) validate(branch HeaderBranch, bh *BlockHeader) error {
+	if o.difficulty {
+		err := CheckHeaderBits(o.params, branch, bh)
+		if err != nil && !(o.root > 0 && errors.Is(err, ErrHeaderNotFound)) {
+			return err
+		}
+	}
+
+	if o.time {
+		if err := CheckHeaderTime(branch, bh, o.now(), o.maxFutureDrift); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
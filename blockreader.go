@@ -0,0 +1,172 @@
+package bc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/libsv/go-bt/v2"
+)
+
+// ErrBlockTruncated is returned when a serialised block ends before all of the
+// transactions it declares have been read.
+var ErrBlockTruncated = errors.New("block is truncated")
+
+// A BlockReader decodes a serialised block from an io.Reader, reading its header and
+// transaction count up front and then each transaction in turn as Next is called.
+//
+// Only one transaction is held at a time so that blocks which are too large to hold
+// in memory can be processed.
+type BlockReader struct {
+	r       io.Reader
+	header  *BlockHeader
+	txCount uint64
+	txsRead uint64
+	n       int64
+}
+
+// NewBlockReader reads the header and transaction count of the block from r and
+// returns a BlockReader positioned at its first transaction. ErrBlockTruncated is
+// returned if r ends before they have been read.
+func NewBlockReader(r io.Reader) (*BlockReader, error) {
+	br := &BlockReader{r: r}
+	if err := br.readPrefix(); err != nil {
+		return nil, err
+	}
+
+	return br, nil
+}
+
+// readPrefix reads the header and transaction count which precede the transactions.
+func (br *BlockReader) readPrefix() error {
+	b := make([]byte, blockHeaderLen)
+	n, err := io.ReadFull(br.r, b)
+	br.n += int64(n)
+	if err != nil {
+		return truncated(err, "block header")
+	}
+	if br.header, err = NewBlockHeaderFromBytes(b); err != nil {
+		return err
+	}
+
+	var txCount bt.VarInt
+	n64, err := txCount.ReadFrom(br.r)
+	br.n += n64
+	if err != nil {
+		return truncated(err, "tx count")
+	}
+	br.txCount = uint64(txCount)
+
+	return nil
+}
+
+// Header returns the header of the block.
+func (br *BlockReader) Header() *BlockHeader {
+	return br.header
+}
+
+// TxCount returns the number of transactions the block declares.
+func (br *BlockReader) TxCount() uint64 {
+	return br.txCount
+}
+
+// BytesRead returns the number of bytes read from the underlying reader so far.
+func (br *BlockReader) BytesRead() int64 {
+	return br.n
+}
+
+// Next reads the next transaction of the block. io.EOF is returned once every
+// transaction has been read, and ErrBlockTruncated if the reader ends part way
+// through a transaction or before the declared number of transactions is reached.
+func (br *BlockReader) Next() (*bt.Tx, error) {
+	if br.txsRead >= br.txCount {
+		return nil, io.EOF
+	}
+
+	tx := new(bt.Tx)
+	n, err := tx.ReadFrom(br.r)
+	br.n += n
+	if err != nil {
+		return nil, truncated(err, fmt.Sprintf("tx %d of %d", br.txsRead, br.txCount))
+	}
+	br.txsRead++
+
+	return tx, nil
+}
+
+// ReadFrom reads a whole serialised block from r into the receiving Block. Use a
+// BlockReader to process the transactions of a block without holding them all in
+// memory.
+func (b *Block) ReadFrom(r io.Reader) (int64, error) {
+	br := &BlockReader{r: r}
+	if err := br.readPrefix(); err != nil {
+		return br.n, err
+	}
+
+	// The declared count is untrusted so is only used as a capacity hint, limited
+	// so that a bogus count can't force a large allocation.
+	txs := make([]*bt.Tx, 0, minUint64(br.txCount, 1<<16))
+	for {
+		tx, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return br.n, err
+		}
+		txs = append(txs, tx)
+	}
+
+	b.BlockHeader = br.header
+	b.Txs = txs
+
+	return br.n, nil
+}
+
+// WriteTo writes the serialised block to w. ErrMalformedHeader is returned if the
+// fields of its header have the wrong length.
+func (b *Block) WriteTo(w io.Writer) (int64, error) {
+	header, err := b.BlockHeader.Bytes()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		total += int64(n)
+		return err
+	}
+
+	if err := write(header); err != nil {
+		return total, err
+	}
+	if err := write(bt.VarInt(uint64(len(b.Txs))).Bytes()); err != nil {
+		return total, err
+	}
+	for _, tx := range b.Txs {
+		if err := write(tx.Bytes()); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// truncated wraps an error from reading part of a block, reporting ErrBlockTruncated
+// when it is because the reader ended.
+func truncated(err error, part string) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: reading %s: %v", ErrBlockTruncated, part, err)
+	}
+
+	return fmt.Errorf("reading %s: %w", part, err)
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,103 @@
+package bc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestBlockReader(t *testing.T) {
+	t.Parallel()
+
+	blockBytes, err := hex.DecodeString(testBlockStr)
+	assert.NoError(t, err)
+	block, err := bc.NewBlockFromBytes(blockBytes)
+	assert.NoError(t, err)
+
+	br, err := bc.NewBlockReader(bytes.NewReader(blockBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockHeader, br.Header())
+	assert.Equal(t, uint64(3), br.TxCount())
+
+	for i := 0; i < 3; i++ {
+		tx, err := br.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, block.Txs[i].TxID(), tx.TxID())
+	}
+
+	_, err = br.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, int64(len(blockBytes)), br.BytesRead())
+}
+
+func TestBlockReader_Truncated(t *testing.T) {
+	t.Parallel()
+
+	blockBytes, err := hex.DecodeString(testBlockStr)
+	assert.NoError(t, err)
+	block, err := bc.NewBlockFromBytes(blockBytes)
+	assert.NoError(t, err)
+	lastTx := len(blockBytes) - len(block.Txs[2].Bytes())
+
+	tests := map[string]struct {
+		length int
+	}{
+		"no data": {
+			length: 0,
+		},
+		"part of header": {
+			length: 40,
+		},
+		"no tx count": {
+			length: 80,
+		},
+		"part of first tx": {
+			length: 100,
+		},
+		"missing last tx": {
+			length: lastTx,
+		},
+		"missing last byte": {
+			length: len(blockBytes) - 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var block bc.Block
+			_, err := block.ReadFrom(bytes.NewReader(blockBytes[:test.length]))
+			assert.True(t, errors.Is(err, bc.ErrBlockTruncated), "unexpected error %v", err)
+		})
+	}
+
+	_, err = bc.NewBlockFromBytes(blockBytes[:lastTx])
+	assert.True(t, errors.Is(err, bc.ErrBlockTruncated))
+}
+
+func TestBlock_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	blockBytes, err := hex.DecodeString(testBlockStr)
+	assert.NoError(t, err)
+
+	var block bc.Block
+	n, err := block.ReadFrom(bytes.NewReader(blockBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(blockBytes)), n)
+
+	var buf bytes.Buffer
+	n, err = block.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(blockBytes)), n)
+	assert.Equal(t, blockBytes, buf.Bytes())
+
+	block.BlockHeader.Bits = nil
+	_, err = block.WriteTo(&buf)
+	assert.True(t, errors.Is(err, bc.ErrMalformedHeader))
+}
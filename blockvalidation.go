@@ -0,0 +1,227 @@
+package bc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/libsv/go-bt/v2"
+)
+
+var (
+	// ErrBlockNoTxs is returned when a block has no transactions, so no coinbase.
+	ErrBlockNoTxs = errors.New("block has no transactions")
+	// ErrBadMerkleRoot is returned when the merkle root of a block's transactions
+	// doesn't match the merkle root in its header.
+	ErrBadMerkleRoot = errors.New("merkle root does not match block header")
+	// ErrMerkleMutated is returned when a block's transactions include duplicates
+	// arranged so that the block has the same merkle root as the block without them,
+	// as described by CVE-2012-2459.
+	ErrMerkleMutated = errors.New("block merkle tree is mutated")
+	// ErrFirstTxNotCoinbase is returned when the first transaction of a block isn't a coinbase.
+	ErrFirstTxNotCoinbase = errors.New("first transaction is not a coinbase")
+	// ErrMultipleCoinbase is returned when a transaction other than the first is a coinbase.
+	ErrMultipleCoinbase = errors.New("block has more than one coinbase")
+	// ErrDuplicateTx is returned when a block contains the same transaction more than once.
+	ErrDuplicateTx = errors.New("block contains duplicate transactions")
+	// ErrBadCoinbaseHeight is returned when the coinbase doesn't start with the height
+	// of its block as required by BIP34.
+	ErrBadCoinbaseHeight = errors.New("coinbase does not start with block height")
+	// ErrCoinbaseValueTooHigh is returned when the coinbase pays more than the block
+	// subsidy plus the fees of the block's transactions.
+	ErrCoinbaseValueTooHigh = errors.New("coinbase pays more than subsidy and fees")
+)
+
+// A PrevOutputFetcher returns the output with index vout of the transaction txID,
+// which is spent by an input of a block.
+type PrevOutputFetcher interface {
+	PrevOutput(txID string, vout uint32) (*bt.Output, error)
+}
+
+type blockValidateOptions struct {
+	prevOutputs PrevOutputFetcher
+}
+
+// BlockValidateOpt defines a functional option that is used to modify the validation
+// of a block.
+type BlockValidateOpt func(opts *blockValidateOptions)
+
+// ValidateCoinbaseValue will make block validation check that the coinbase pays no more
+// than the block subsidy plus fees. The outputs spent by the block's transactions are
+// looked up with prevOutputs, except for those created earlier in the same block.
+func ValidateCoinbaseValue(prevOutputs PrevOutputFetcher) BlockValidateOpt {
+	return func(opts *blockValidateOptions) {
+		opts.prevOutputs = prevOutputs
+	}
+}
+
+// Validate checks the block, expected to be at height on the network of params, is
+// consistent with its header and follows the coinbase rules:
+//
+// - the merkle root of its transactions matches its header, and the merkle tree isn't
+// mutated by duplicated transactions (CVE-2012-2459)
+// - its first transaction, and only its first, is a coinbase
+// - none of its transactions appear twice
+// - from BIP34Height, the coinbase starts with the height of the block
+// - if ValidateCoinbaseValue is provided, the coinbase pays no more than the block
+// subsidy plus fees.
+//
+// Scripts and the transactions themselves aren't validated.
+func (b *Block) Validate(params *ChainParams, height uint32, opts ...BlockValidateOpt) error {
+	o := &blockValidateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := checkHeaderFields(b.BlockHeader); err != nil {
+		return err
+	}
+	if len(b.Txs) == 0 {
+		return ErrBlockNoTxs
+	}
+
+	txIDs := b.TxIDs()
+	root, mutated := merkleRootMutated(txIDs)
+	if headerRoot, _ := NewHashFromDisplayBytes(b.BlockHeader.HashMerkleRoot); root != headerRoot {
+		return fmt.Errorf("%w: calculated %s, header %s", ErrBadMerkleRoot, root, headerRoot)
+	}
+	if mutated {
+		return ErrMerkleMutated
+	}
+
+	if !b.Txs[0].IsCoinbase() {
+		return ErrFirstTxNotCoinbase
+	}
+	seen := make(map[Hash]int, len(txIDs))
+	for i, txID := range txIDs {
+		if i > 0 && b.Txs[i].IsCoinbase() {
+			return fmt.Errorf("%w: tx %d is a coinbase", ErrMultipleCoinbase, i)
+		}
+		if j, ok := seen[txID]; ok {
+			return fmt.Errorf("%w: %s at %d and %d", ErrDuplicateTx, txID, j, i)
+		}
+		seen[txID] = i
+	}
+
+	if height >= params.BIP34Height {
+		expected := coinbaseHeightScript(height)
+		if script := b.Txs[0].Inputs[0].UnlockingScript; script == nil || !bytes.HasPrefix(*script, expected) {
+			return fmt.Errorf("%w: expected height %d", ErrBadCoinbaseHeight, height)
+		}
+	}
+
+	if o.prevOutputs != nil {
+		return b.checkCoinbaseValue(params, height, seen, o.prevOutputs)
+	}
+
+	return nil
+}
+
+// checkCoinbaseValue returns ErrCoinbaseValueTooHigh if the coinbase pays more than the
+// subsidy at height plus fees. index maps the txids of the block to their position so
+// that outputs spent from earlier in the block can be found.
+func (b *Block) checkCoinbaseValue(params *ChainParams, height uint32, index map[Hash]int, prevOutputs PrevOutputFetcher) error {
+	var fees uint64
+	for i, tx := range b.Txs[1:] {
+		var in uint64
+		for _, input := range tx.Inputs {
+			output, err := b.prevOutput(index, i+1, input, prevOutputs)
+			if err != nil {
+				return err
+			}
+			in += output.Satoshis
+		}
+
+		out := tx.TotalOutputSatoshis()
+		if out > in {
+			return fmt.Errorf("tx %s spends %d satoshis more than its inputs", tx.TxID(), out-in)
+		}
+		fees += in - out
+	}
+
+	limit := params.BlockSubsidy(height) + fees
+	if value := b.Txs[0].TotalOutputSatoshis(); value > limit {
+		return fmt.Errorf("%w: pays %d, limit %d", ErrCoinbaseValueTooHigh, value, limit)
+	}
+
+	return nil
+}
+
+// prevOutput returns the output spent by input of the tx at position pos in the block.
+func (b *Block) prevOutput(index map[Hash]int, pos int, input *bt.Input, prevOutputs PrevOutputFetcher) (*bt.Output, error) {
+	prevID, err := NewHashFromDisplayBytes(input.PreviousTxID())
+	if err != nil {
+		return nil, err
+	}
+
+	if i, ok := index[prevID]; ok && i < pos {
+		outputs := b.Txs[i].Outputs
+		if int(input.PreviousTxOutIndex) >= len(outputs) {
+			return nil, fmt.Errorf("tx %s has no output %d", prevID, input.PreviousTxOutIndex)
+		}
+		return outputs[input.PreviousTxOutIndex], nil
+	}
+
+	output, err := prevOutputs.PrevOutput(prevID.String(), input.PreviousTxOutIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output %s:%d: %w", prevID, input.PreviousTxOutIndex, err)
+	}
+
+	return output, nil
+}
+
+// merkleRootMutated returns the merkle root of the txids along with whether the tree
+// is mutated, which is when two identical nodes are hashed together other than when
+// the last node of a layer is duplicated. Such a tree has the same root as the tree
+// without the duplicated nodes.
+//
+// See https://github.com/bitcoin-sv/bitcoin-sv/blob/master/src/consensus/merkle.cpp
+func merkleRootMutated(txIDs []Hash) (Hash, bool) {
+	if len(txIDs) == 0 {
+		return Hash{}, false
+	}
+
+	layer := append([]Hash{}, txIDs...)
+	var mutated bool
+	for len(layer) > 1 {
+		for i := 0; i+1 < len(layer); i += 2 {
+			if layer[i] == layer[i+1] {
+				mutated = true
+			}
+		}
+		if len(layer)%2 == 1 {
+			layer = append(layer, layer[len(layer)-1])
+		}
+
+		next := layer[:0]
+		for i := 0; i < len(layer); i += 2 {
+			next = append(next, TypedMerkleTreeParent(layer[i], layer[i+1]))
+		}
+		layer = next
+	}
+
+	return layer[0], mutated
+}
+
+// coinbaseHeightScript returns the script pushing height that BIP34 requires the
+// coinbase to start with, the same as the node's CScript() << height.
+func coinbaseHeightScript(height uint32) []byte {
+	if height == 0 {
+		return []byte{0x00}
+	}
+	if height <= 16 {
+		return []byte{0x50 + byte(height)}
+	}
+
+	var num []byte
+	for h := height; h > 0; h >>= 8 {
+		num = append(num, byte(h))
+	}
+	// The most significant bit is the sign bit, so a positive number with it set
+	// needs an extra byte.
+	if num[len(num)-1]&0x80 != 0 {
+		num = append(num, 0x00)
+	}
+
+	return append([]byte{byte(len(num))}, num...)
+}
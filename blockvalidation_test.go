@@ -0,0 +1,152 @@
+package bc_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+// fixedPrevOutputs returns an output of the same value for every outpoint.
+type fixedPrevOutputs uint64
+
+func (f fixedPrevOutputs) PrevOutput(txID string, vout uint32) (*bt.Output, error) {
+	return &bt.Output{Satoshis: uint64(f)}, nil
+}
+
+var errOutputNotFound = errors.New("output not found")
+
+type missingPrevOutputs struct{}
+
+func (missingPrevOutputs) PrevOutput(txID string, vout uint32) (*bt.Output, error) {
+	return nil, fmt.Errorf("%w: %s:%d", errOutputNotFound, txID, vout)
+}
+
+// testBlock returns the block of testBlockStr with its transactions replaced by those
+// at the indexes in order, and when fixRoot is set its header updated to the merkle
+// root of the new transactions.
+func testBlock(t *testing.T, order []int, fixRoot bool) *bc.Block {
+	t.Helper()
+
+	b, err := bc.NewBlockFromStr(testBlockStr)
+	assert.NoError(t, err)
+	if order == nil {
+		return b
+	}
+
+	txs := make([]*bt.Tx, 0, len(order))
+	txIDs := make([]string, 0, len(order))
+	for _, i := range order {
+		txs = append(txs, b.Txs[i])
+		txIDs = append(txIDs, b.Txs[i].TxID())
+	}
+	b.Txs = txs
+
+	if fixRoot {
+		root, err := bc.BuildMerkleRoot(txIDs)
+		assert.NoError(t, err)
+		b.BlockHeader.HashMerkleRoot, err = hex.DecodeString(root)
+		assert.NoError(t, err)
+	}
+
+	return b
+}
+
+func TestBlock_Validate(t *testing.T) {
+	t.Parallel()
+
+	// the coinbase of the test block is at height 2894 and pays 10444 satoshis.
+	params := *bc.RegTestParams
+	params.BIP34Height = 0
+
+	tests := map[string]struct {
+		block  *bc.Block
+		height uint32
+		opts   []bc.BlockValidateOpt
+		expErr error
+	}{
+		"valid block": {
+			block:  testBlock(t, nil, false),
+			height: 2894,
+		},
+		"valid coinbase value": {
+			block:  testBlock(t, nil, false),
+			height: 2894,
+			opts:   []bc.BlockValidateOpt{bc.ValidateCoinbaseValue(fixedPrevOutputs(367100))},
+		},
+		"coinbase value above subsidy and fees": {
+			block:  testBlock(t, nil, false),
+			height: 2894,
+			opts:   []bc.BlockValidateOpt{bc.ValidateCoinbaseValue(fixedPrevOutputs(367000))},
+			expErr: bc.ErrCoinbaseValueTooHigh,
+		},
+		"missing previous outputs": {
+			block:  testBlock(t, nil, false),
+			height: 2894,
+			opts:   []bc.BlockValidateOpt{bc.ValidateCoinbaseValue(missingPrevOutputs{})},
+			expErr: errOutputNotFound,
+		},
+		"wrong coinbase height": {
+			block:  testBlock(t, nil, false),
+			height: 2895,
+			expErr: bc.ErrBadCoinbaseHeight,
+		},
+		"bad merkle root": {
+			block:  testBlock(t, []int{0, 2, 1}, false),
+			height: 2894,
+			expErr: bc.ErrBadMerkleRoot,
+		},
+		"no transactions": {
+			block: func() *bc.Block {
+				b := testBlock(t, nil, false)
+				b.Txs = nil
+				return b
+			}(),
+			height: 2894,
+			expErr: bc.ErrBlockNoTxs,
+		},
+		"first tx not coinbase": {
+			block:  testBlock(t, []int{1, 0, 2}, true),
+			height: 2894,
+			expErr: bc.ErrFirstTxNotCoinbase,
+		},
+		"second coinbase": {
+			block:  testBlock(t, []int{0, 1, 0, 2}, true),
+			height: 2894,
+			expErr: bc.ErrMultipleCoinbase,
+		},
+		"duplicate tx": {
+			block:  testBlock(t, []int{0, 1, 2, 1}, true),
+			height: 2894,
+			expErr: bc.ErrDuplicateTx,
+		},
+		"duplicated last tx has the same merkle root": {
+			block:  testBlock(t, []int{0, 1, 2, 2}, false),
+			height: 2894,
+			expErr: bc.ErrMerkleMutated,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.block.Validate(&params, test.height, test.opts...)
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr), "unexpected error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBlock_Validate_BIP34Height(t *testing.T) {
+	t.Parallel()
+
+	// below BIP34Height the coinbase needn't contain the height.
+	assert.NoError(t, testBlock(t, nil, false).Validate(bc.RegTestParams, 2895))
+}
@@ -0,0 +1,168 @@
+package bc
+
+import (
+	"math/big"
+	"time"
+)
+
+// A Checkpoint pins the hash of the block at a height of a chain.
+type Checkpoint struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// ChainParams define a bitcoin network: its genesis header, the difficulty adjustment
+// rules its headers follow, the checkpoints it is pinned to and its subsidy schedule.
+type ChainParams struct {
+	Name string
+
+	// Genesis is the first header of the chain.
+	Genesis *BlockHeader
+
+	// PowLimit is the highest target, so the lowest difficulty, a block may have.
+	PowLimit *big.Int
+	// PowLimitBits is PowLimit in compact form.
+	PowLimitBits uint32
+	// TargetTimespan is the time over which the original algorithm retargets, and
+	// TargetSpacing the desired time between blocks.
+	TargetTimespan time.Duration
+	TargetSpacing  time.Duration
+	// AllowMinDifficultyBlocks enables the testnet rule allowing a block to have the
+	// lowest difficulty when it is more than twice TargetSpacing after its parent.
+	AllowMinDifficultyBlocks bool
+	// NoRetargeting keeps the difficulty fixed, as on regtest.
+	NoRetargeting bool
+	// DAAHeight is the height from which the block after it uses the cw-144 difficulty
+	// adjustment algorithm rather than the original and emergency algorithms.
+	DAAHeight uint32
+
+	// Checkpoints are ordered by height.
+	Checkpoints []Checkpoint
+
+	// BIP34Height is the height from which the coinbase must start with the height
+	// of its block.
+	BIP34Height uint32
+	// GenesisActivationHeight is the height of the first block of the Genesis upgrade.
+	// The outputs of txs mined below it are spent under the script rules from before it.
+	GenesisActivationHeight uint32
+
+	// InitialSubsidy is the subsidy in satoshis of the first block, which halves
+	// every SubsidyHalvingInterval blocks.
+	InitialSubsidy         uint64
+	SubsidyHalvingInterval uint32
+}
+
+var (
+	mainPowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224), big.NewInt(1))
+	regPowLimit  = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+)
+
+var (
+	// MainNetParams are the parameters of mainnet.
+	MainNetParams = &ChainParams{
+		Name:                    "mainnet",
+		Genesis:                 mustBlockHeader("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"),
+		PowLimit:                mainPowLimit,
+		PowLimitBits:            0x1d00ffff,
+		TargetTimespan:          14 * 24 * time.Hour,
+		TargetSpacing:           10 * time.Minute,
+		DAAHeight:               504031,
+		BIP34Height:             227931,
+		GenesisActivationHeight: 620538,
+		Checkpoints: []Checkpoint{
+			{Height: 11111, Hash: "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"},
+			{Height: 33333, Hash: "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"},
+			{Height: 74000, Hash: "0000000000573993a3c9e41ce34471c079dcf5f52a0e824a81e7f953b8661a20"},
+			{Height: 105000, Hash: "00000000000291ce28027faea320c8d2b054b2e0fe44a773f3eefb151d6bdc97"},
+			{Height: 134444, Hash: "00000000000005b12ffd4cd315cd34ffd4a594f430ac814c91184a0d42d2b0fe"},
+			{Height: 168000, Hash: "000000000000099e61ea72015e79632f216fe6cb33d7899acb35b75c8303b763"},
+			{Height: 193000, Hash: "000000000000059f452a5f7340de6682a977387c17010ff6e6c3bd83ca8b1317"},
+			{Height: 210000, Hash: "000000000000048b95347e83192f69cf0366076336c639f9b7228e9ba171342e"},
+			{Height: 216116, Hash: "00000000000001b4f4b433e81ee46494af945cf96014816a4e2370f11b23df4e"},
+			{Height: 225430, Hash: "00000000000001c108384350f74090433e7fcf79a606b8e797f065b130575932"},
+			{Height: 250000, Hash: "000000000000003887df1f29024b06fc2200b55f8af8f35453d7be294df2d214"},
+			{Height: 279000, Hash: "0000000000000001ae8c72a0b0c301f67e3afca10e819efa9041e458e9bd7e40"},
+			{Height: 295000, Hash: "00000000000000004d9b4ef50f0f9d686fd69db2e03af35a100370c64632a983"},
+			{Height: 478558, Hash: "0000000000000000011865af4122fe3b144e2cbeea86142e8ff2fb4107352d43"},
+		},
+		InitialSubsidy:         50 * 1e8,
+		SubsidyHalvingInterval: 210000,
+	}
+
+	// TestNetParams are the parameters of testnet.
+	TestNetParams = &ChainParams{
+		Name:                     "testnet",
+		Genesis:                  mustBlockHeader("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae18"),
+		PowLimit:                 mainPowLimit,
+		PowLimitBits:             0x1d00ffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetSpacing:            10 * time.Minute,
+		AllowMinDifficultyBlocks: true,
+		DAAHeight:                1188697,
+		BIP34Height:              21111,
+		GenesisActivationHeight:  1344302,
+		Checkpoints: []Checkpoint{
+			{Height: 546, Hash: "000000002a936ca763904c3c35fce2f3556c559c0214345d31b1bcebf76acb70"},
+		},
+		InitialSubsidy:         50 * 1e8,
+		SubsidyHalvingInterval: 210000,
+	}
+
+	// STNParams are the parameters of the scaling test network.
+	STNParams = &ChainParams{
+		Name:                     "stn",
+		Genesis:                  mustBlockHeader("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae18"),
+		PowLimit:                 mainPowLimit,
+		PowLimitBits:             0x1d00ffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetSpacing:            10 * time.Minute,
+		AllowMinDifficultyBlocks: true,
+		DAAHeight:                2200,
+		BIP34Height:              100,
+		GenesisActivationHeight:  100,
+		InitialSubsidy:           50 * 1e8,
+		SubsidyHalvingInterval:   210000,
+	}
+
+	// RegTestParams are the parameters of regtest.
+	RegTestParams = &ChainParams{
+		Name:                     "regtest",
+		Genesis:                  mustBlockHeader("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff7f2002000000"),
+		PowLimit:                 regPowLimit,
+		PowLimitBits:             0x207fffff,
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetSpacing:            10 * time.Minute,
+		AllowMinDifficultyBlocks: true,
+		NoRetargeting:            true,
+		BIP34Height:              100000000,
+		GenesisActivationHeight:  10000,
+		InitialSubsidy:           50 * 1e8,
+		SubsidyHalvingInterval:   150,
+	}
+)
+
+// BlockSubsidy returns the subsidy in satoshis a miner may claim in the coinbase of
+// the block at height, excluding fees.
+func (p *ChainParams) BlockSubsidy(height uint32) uint64 {
+	halvings := height / p.SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+
+	return p.InitialSubsidy >> halvings
+}
+
+// adjustmentInterval returns the number of blocks between retargets of the original
+// difficulty adjustment algorithm.
+func (p *ChainParams) adjustmentInterval() uint32 {
+	return uint32(p.TargetTimespan / p.TargetSpacing)
+}
+
+func mustBlockHeader(headerStr string) *BlockHeader {
+	bh, err := NewBlockHeaderFromStr(headerStr)
+	if err != nil {
+		panic(err)
+	}
+
+	return bh
+}
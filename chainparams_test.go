@@ -0,0 +1,122 @@
+package bc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestChainParams_Genesis(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		params  *bc.ChainParams
+		expHash string
+	}{
+		"mainnet": {
+			params:  bc.MainNetParams,
+			expHash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		},
+		"testnet": {
+			params:  bc.TestNetParams,
+			expHash: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		},
+		"stn": {
+			params:  bc.STNParams,
+			expHash: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		},
+		"regtest": {
+			params:  bc.RegTestParams,
+			expHash: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expHash, headerHashStr(test.params.Genesis))
+			assert.True(t, test.params.Genesis.Valid())
+			assert.Equal(t, test.params.PowLimitBits, bc.BigToCompact(test.params.PowLimit))
+		})
+	}
+}
+
+func TestChainParams_BlockSubsidy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		params     *bc.ChainParams
+		height     uint32
+		expSubsidy uint64
+	}{
+		"mainnet genesis": {
+			params:     bc.MainNetParams,
+			height:     0,
+			expSubsidy: 5000000000,
+		},
+		"mainnet before first halving": {
+			params:     bc.MainNetParams,
+			height:     209999,
+			expSubsidy: 5000000000,
+		},
+		"mainnet first halving": {
+			params:     bc.MainNetParams,
+			height:     210000,
+			expSubsidy: 2500000000,
+		},
+		"mainnet fourth halving": {
+			params:     bc.MainNetParams,
+			height:     840000,
+			expSubsidy: 312500000,
+		},
+		"mainnet subsidy runs out": {
+			params:     bc.MainNetParams,
+			height:     64 * 210000,
+			expSubsidy: 0,
+		},
+		"regtest halves every 150 blocks": {
+			params:     bc.RegTestParams,
+			height:     300,
+			expSubsidy: 1250000000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expSubsidy, test.params.BlockSubsidy(test.height))
+		})
+	}
+}
+
+func TestChainParams_GenesisActivationHeight(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		params    *bc.ChainParams
+		expHeight uint32
+	}{
+		"mainnet": {
+			params:    bc.MainNetParams,
+			expHeight: 620538,
+		},
+		"testnet": {
+			params:    bc.TestNetParams,
+			expHeight: 1344302,
+		},
+		"stn": {
+			params:    bc.STNParams,
+			expHeight: 100,
+		},
+		"regtest": {
+			params:    bc.RegTestParams,
+			expHeight: 10000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expHeight, test.params.GenesisActivationHeight)
+		})
+	}
+}
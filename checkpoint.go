@@ -0,0 +1,60 @@
+package bc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrCheckpointMismatch is returned when a header is at the height of a checkpoint
+	// but doesn't have its hash.
+	ErrCheckpointMismatch = errors.New("header conflicts with a checkpoint")
+	// ErrForkBelowCheckpoint is returned when a header forks from the chain below the last
+	// checkpoint it has reached, or when a chain is truncated below that checkpoint.
+	ErrForkBelowCheckpoint = errors.New("header forks from the chain below the last checkpoint")
+)
+
+// CheckCheckpoint returns ErrCheckpointMismatch if there is a checkpoint at height whose
+// hash isn't blockHash.
+func CheckCheckpoint(checkpoints []Checkpoint, height uint32, blockHash string) error {
+	for _, cp := range checkpoints {
+		if cp.Height == height && cp.Hash != blockHash {
+			return fmt.Errorf("%w: expected %s at height %d got %s", ErrCheckpointMismatch, cp.Hash, height, blockHash)
+		}
+	}
+
+	return nil
+}
+
+// LastCheckpoint returns the highest checkpoint at or below height, or nil if there
+// isn't one.
+func LastCheckpoint(checkpoints []Checkpoint, height uint32) *Checkpoint {
+	var last *Checkpoint
+	for i := range checkpoints {
+		if checkpoints[i].Height <= height && (last == nil || checkpoints[i].Height > last.Height) {
+			last = &checkpoints[i]
+		}
+	}
+
+	return last
+}
+
+// checkCheckpoints applies the checkpoint rules, if enabled, to a header at height with
+// blockHash being added to a chain whose active tip is at tipHeight. The header must
+// match any checkpoint at its height and mustn't be below the last checkpoint the
+// chain has reached, since it would then fork from the chain before the checkpoint.
+func (o *headerChainOptions) checkCheckpoints(height, tipHeight uint32, blockHash string) error {
+	if !o.verifyCheckpoints {
+		return nil
+	}
+
+	if err := CheckCheckpoint(o.checkpoints, height, blockHash); err != nil {
+		return err
+	}
+
+	if last := LastCheckpoint(o.checkpoints, tipHeight); last != nil && height < last.Height {
+		return fmt.Errorf("%w: header at height %d, checkpoint at height %d", ErrForkBelowCheckpoint, height, last.Height)
+	}
+
+	return nil
+}
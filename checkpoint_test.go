@@ -0,0 +1,163 @@
+package bc_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestLastCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	checkpoints := []bc.Checkpoint{{Height: 20, Hash: "b"}, {Height: 10, Hash: "a"}, {Height: 30, Hash: "c"}}
+
+	tests := map[string]struct {
+		height  uint32
+		expHash string
+	}{
+		"below first checkpoint": {
+			height: 9,
+		},
+		"at a checkpoint": {
+			height:  20,
+			expHash: "b",
+		},
+		"between checkpoints": {
+			height:  29,
+			expHash: "b",
+		},
+		"above last checkpoint": {
+			height:  100,
+			expHash: "c",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cp := bc.LastCheckpoint(checkpoints, test.height)
+			if test.expHash == "" {
+				assert.Nil(t, cp)
+				return
+			}
+			assert.Equal(t, test.expHash, cp.Hash)
+		})
+	}
+}
+
+func TestBlockHeaderChain_VerifyCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	main := mineBranch(t, regtestGenesis, 4, "main")
+	params := *bc.RegTestParams
+	params.Checkpoints = []bc.Checkpoint{{Height: 2, Hash: headerHashStr(main[1])}}
+
+	mc, err := bc.NewMemoryBlockHeaderChain(&params)
+	assert.NoError(t, err)
+	fc, err := bc.OpenFileBlockHeaderChain(filepath.Join(t.TempDir(), "headers.dat"), &params)
+	assert.NoError(t, err)
+	defer fc.Close()
+
+	for _, c := range []interface {
+		AddHeader(*bc.BlockHeader) error
+	}{mc, fc} {
+		assert.NoError(t, c.AddHeader(main[0]))
+		assert.True(t, errors.Is(c.AddHeader(mineHeader(t, main[0], "conflict")), bc.ErrCheckpointMismatch))
+
+		for _, bh := range main[1:] {
+			assert.NoError(t, c.AddHeader(bh))
+		}
+
+		err := c.AddHeader(mineHeader(t, regtestGenesis, "fork"))
+		assert.True(t, errors.Is(err, bc.ErrForkBelowCheckpoint))
+	}
+
+	// forks above the checkpoint are still accepted.
+	assert.NoError(t, mc.AddHeader(mineHeader(t, main[1], "fork")))
+	assert.True(t, errors.Is(fc.Truncate(1), bc.ErrForkBelowCheckpoint))
+	assert.NoError(t, fc.Truncate(2))
+	assert.NoError(t, fc.AddHeader(mineHeader(t, main[1], "fork")))
+}
+
+func TestBlockHeaderChain_NoVerifyCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	main := mineBranch(t, regtestGenesis, 2, "main")
+	conflict := mineHeader(t, main[0], "conflict")
+	params := *bc.RegTestParams
+	params.Checkpoints = []bc.Checkpoint{{Height: 2, Hash: headerHashStr(main[1])}}
+
+	c, err := bc.NewMemoryBlockHeaderChain(&params, bc.NoVerifyCheckpoints())
+	assert.NoError(t, err)
+	for _, bh := range append(main, conflict) {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+
+	// additional checkpoints are pinned alongside those of the params.
+	c, err = bc.NewMemoryBlockHeaderChain(bc.RegTestParams, bc.VerifyCheckpoints(params.Checkpoints...))
+	assert.NoError(t, err)
+	assert.NoError(t, c.AddHeader(main[0]))
+	assert.True(t, errors.Is(c.AddHeader(conflict), bc.ErrCheckpointMismatch))
+}
+
+func TestBlockHeaderChain_FromCheckpoint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	headers := mineBranch(t, regtestGenesis, 8, "main")
+	params := *bc.RegTestParams
+	params.Checkpoints = []bc.Checkpoint{{Height: 3, Hash: headerHashStr(headers[2])}}
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	mc, err := bc.NewMemoryBlockHeaderChainFromCheckpoint(&params, 3, headers[2])
+	assert.NoError(t, err)
+	fc, err := bc.OpenFileBlockHeaderChainFromCheckpoint(path, &params, 3, headers[2])
+	assert.NoError(t, err)
+
+	for _, c := range []interface {
+		bc.MedianTimePastChain
+		AddHeader(*bc.BlockHeader) error
+		HeaderAtHeight(uint32) (*bc.BlockHeader, error)
+		Height() uint32
+	}{mc, fc} {
+		for _, bh := range headers[3:] {
+			assert.NoError(t, c.AddHeader(bh))
+		}
+		assert.Equal(t, uint32(8), c.Height())
+
+		bh, err := c.HeaderAtHeight(5)
+		assert.NoError(t, err)
+		assert.Equal(t, headers[4].String(), bh.String())
+
+		_, err = c.HeaderAtHeight(2)
+		assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+		_, err = c.BlockHeader(ctx, headerHashStr(headers[0]))
+		assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+
+		// only the six headers from the root are available to the median.
+		mtp, err := c.MedianTimePast(ctx, headerHashStr(headers[7]))
+		assert.NoError(t, err)
+		assert.Equal(t, headers[5].Time, mtp)
+
+		err = c.AddHeader(mineHeader(t, headers[1], "below root"))
+		assert.True(t, errors.Is(err, bc.ErrOrphanHeader))
+	}
+
+	assert.NoError(t, fc.Close())
+	fc, err = bc.OpenFileBlockHeaderChainFromCheckpoint(path, &params, 3, headers[2])
+	assert.NoError(t, err)
+	defer fc.Close()
+	assert.Equal(t, uint32(8), fc.Height())
+	hash, err := fc.HashAtHeight(8)
+	assert.NoError(t, err)
+	assert.Equal(t, headerHashStr(headers[7]), hash)
+
+	_, err = bc.NewMemoryBlockHeaderChainFromCheckpoint(&params, 3, headers[3])
+	assert.True(t, errors.Is(err, bc.ErrCheckpointMismatch))
+	_, err = bc.OpenFileBlockHeaderChainFromCheckpoint(path, &params, 4, headers[3])
+	assert.True(t, errors.Is(err, bc.ErrCorruptHeaderFile))
+}
@@ -58,7 +58,8 @@ func BuildCoinbase(c1 []byte, c2 []byte, extraNonce1 string, extraNonce2 string)
 	return a
 }
 
-// GetCoinbaseParts returns the two split coinbase parts from coinbase metadata.
+// GetCoinbaseParts returns the two split coinbase parts from coinbase metadata, paying
+// coinbaseValue, the block subsidy and fees in total, to walletAddress.
 // See https://arxiv.org/pdf/1703.06545.pdf section 2.2 for more info.
 func GetCoinbaseParts(height uint32, coinbaseValue uint64, defaultWitnessCommitment string, coinbaseText string,
 	walletAddress string, minerIDBytes []byte) (coinbase1 []byte, coinbase2 []byte, err error) {
@@ -74,6 +75,15 @@ func GetCoinbaseParts(height uint32, coinbaseValue uint64, defaultWitnessCommitm
 	return
 }
 
+// GetCoinbasePartsWithSubsidy returns the two split coinbase parts in the same way as
+// GetCoinbaseParts, paying the block subsidy of the network params at height plus fees
+// to walletAddress.
+func GetCoinbasePartsWithSubsidy(params *ChainParams, height uint32, fees uint64, defaultWitnessCommitment string,
+	coinbaseText string, walletAddress string, minerIDBytes []byte) (coinbase1 []byte, coinbase2 []byte, err error) {
+	return GetCoinbaseParts(height, params.BlockSubsidy(height)+fees, defaultWitnessCommitment, coinbaseText,
+		walletAddress, minerIDBytes)
+}
+
 //nolint:makezero
 func makeCoinbaseOutputTransactions(coinbaseValue uint64, defaultWitnessCommitment string, wallet string, minerIDBytes []byte) ([]byte, error) {
 	tx := bt.NewTx()
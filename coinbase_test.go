@@ -0,0 +1,30 @@
+package bc_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestGetCoinbaseParts(t *testing.T) {
+	t.Parallel()
+
+	const wallet = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	const fees = 1000
+
+	// the value of the first output follows the sequence and the output count.
+	value := func(coinbase2 []byte) uint64 {
+		return binary.LittleEndian.Uint64(coinbase2[5:13])
+	}
+
+	_, c2, err := bc.GetCoinbaseParts(700000, fees, "", "text", wallet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fees), value(c2))
+
+	_, c2, err = bc.GetCoinbasePartsWithSubsidy(bc.MainNetParams, 700000, fees, "", "text", wallet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bc.MainNetParams.BlockSubsidy(700000)+fees, value(c2))
+}
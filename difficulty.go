@@ -3,38 +3,11 @@ package bc
 import (
 	"encoding/binary"
 	"encoding/hex"
-	"log"
 	"math"
 	"math/big"
 	"strconv"
 )
 
-var (
-	genesisDiff float64
-	regDiff     float64
-)
-
-func init() {
-	bn, err := ExpandTargetFromAsInt("1d00ffff")
-	if err != nil {
-		log.Printf("Error: %+v", err)
-	}
-
-	bf := big.NewFloat(0)
-	bf.SetInt(bn)
-	genesisDiff, _ = bf.Float64()
-	genesisDiff = math.Pow(2, 256) / genesisDiff
-
-	bn, err = ExpandTargetFromAsInt("207fffff")
-	if err != nil {
-		log.Printf("Error: %+v", err)
-	}
-
-	bf.SetInt(bn)
-	regDiff, _ = bf.Float64()
-	regDiff = math.Pow(2, 256) / regDiff
-}
-
 // ExpandTargetFrom comment.
 func ExpandTargetFrom(bits string) (string, error) {
 	bn, err := ExpandTargetFromAsInt(bits)
@@ -55,8 +28,44 @@ func ExpandTargetFromAsInt(bits string) (*big.Int, error) {
 	if err != nil {
 		return nil, err
 	}
-	compact := binary.BigEndian.Uint32(binaryBits)
 
+	return CompactToBig(binary.BigEndian.Uint32(binaryBits)), nil
+}
+
+// DifficultyToHashrate takes the params of a network, it's difficulty, and target
+// and computes the estimated hashrate on that network.
+func DifficultyToHashrate(params *ChainParams, diff uint64, targetSeconds float64) float64 {
+	powLimit, _ := new(big.Float).SetInt(CompactToBig(params.PowLimitBits)).Float64()
+	genesis := math.Pow(2, 256) / powLimit
+
+	return float64(diff) * genesis / targetSeconds
+}
+
+// DifficultyFromBits returns the mining difficulty from the nBits field in the block header.
+func DifficultyFromBits(bits []byte) (float64, error) {
+	ib := binary.BigEndian.Uint32(bits)
+	return targetToDifficulty(toCompactSize(ib))
+}
+
+func toCompactSize(bits uint32) *big.Int {
+	t := big.NewInt(int64(bits % 0x01000000))
+	t.Mul(t, big.NewInt(2).Exp(big.NewInt(2), big.NewInt(8*(int64(bits/0x01000000)-3)), nil))
+
+	return t
+}
+
+func targetToDifficulty(target *big.Int) (float64, error) {
+	a := float64(0xFFFF0000000000000000000000000000000000000000000000000000) // genesis difficulty
+	b, err := strconv.ParseFloat(target.String(), 64)
+	if err != nil {
+		return 0.0, err
+	}
+	return a / b, nil
+}
+
+// CompactToBig converts a compact representation of a whole number, as used
+// for the Bits field of a block header, into an unsigned 256-bit number.
+func CompactToBig(compact uint32) *big.Int {
 	// Extract the mantissa, sign bit, and exponent.
 	mantissa := compact & 0x007fffff
 	isNegative := compact&0x00800000 != 0
@@ -81,38 +90,63 @@ func ExpandTargetFromAsInt(bits string) (*big.Int, error) {
 		bn = bn.Neg(bn)
 	}
 
-	return bn, nil
+	return bn
 }
 
-// DifficultyToHashrate takes a specific coin ticker, it's difficulty, and target
-// and computes the estimated hashrate on that specific coin (or chain).
-func DifficultyToHashrate(coin string, diff uint64, targetSeconds float64) float64 {
-	genesis := genesisDiff
-	if coin[0] == 'R' {
-		genesis = regDiff
+// CalcWork calculates a work value from difficulty bits. The work is the
+// expected number of hashes required to produce a block with the target
+// in bits, which is 2^256 / (target+1).
+func CalcWork(bits uint32) *big.Int {
+	// Return a work value of zero if the passed difficulty bits represent
+	// a negative number. Note this should not happen in practice with valid
+	// blocks, but an invalid block could trigger it.
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
 	}
 
-	return float64(diff) * genesis / targetSeconds
+	denominator := new(big.Int).Add(target, big.NewInt(1))
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denominator)
 }
 
-// DifficultyFromBits returns the mining difficulty from the nBits field in the block header.
-func DifficultyFromBits(bits []byte) (float64, error) {
-	ib := binary.BigEndian.Uint32(bits)
-	return targetToDifficulty(toCompactSize(ib))
-}
+// BigToCompact converts a whole number into the compact representation used
+// for the Bits field of a block header. The compact representation only
+// provides 23 bits of precision, so values larger than (2^23 - 1) only encode
+// the most significant digits of the number.
+func BigToCompact(n *big.Int) uint32 {
+	// No need to do any work if it's zero.
+	if n.Sign() == 0 {
+		return 0
+	}
 
-func toCompactSize(bits uint32) *big.Int {
-	t := big.NewInt(int64(bits % 0x01000000))
-	t.Mul(t, big.NewInt(2).Exp(big.NewInt(2), big.NewInt(8*(int64(bits/0x01000000)-3)), nil))
+	// Since the base for the exponent is 256, the exponent can be treated
+	// as the number of bytes.  So, shift the number right or left
+	// accordingly.  This is equivalent to:
+	// mantissa = mantissa / 256^(exponent-3)
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(new(big.Int).Abs(n).Uint64())
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Abs(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Uint64())
+	}
 
-	return t
-}
+	// When the mantissa already has the sign bit set, the number is too
+	// large to fit into the available 23-bits, so divide the number by 256
+	// and increment the exponent accordingly.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
 
-func targetToDifficulty(target *big.Int) (float64, error) {
-	a := float64(0xFFFF0000000000000000000000000000000000000000000000000000) // genesis difficulty
-	b, err := strconv.ParseFloat(target.String(), 64)
-	if err != nil {
-		return 0.0, err
+	// Pack the exponent, sign bit, and mantissa into an unsigned 32-bit
+	// int and return it.
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
 	}
-	return a / b, nil
+
+	return compact
 }
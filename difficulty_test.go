@@ -8,7 +8,7 @@ import (
 )
 
 func TestDifficultyToHashratBSV(t *testing.T) {
-	a := bc.DifficultyToHashrate("BSV", 22000, 7)
+	a := bc.DifficultyToHashrate(bc.MainNetParams, 22000, 7)
 	b := bc.HumanHash(a)
 	expected := "13.50 TH/s"
 	if b != expected {
@@ -17,7 +17,7 @@ func TestDifficultyToHashratBSV(t *testing.T) {
 }
 
 func TestDifficultyToHashrateRSV(t *testing.T) {
-	a := bc.DifficultyToHashrate("RSV", 22000, 7)
+	a := bc.DifficultyToHashrate(bc.RegTestParams, 22000, 7)
 	b := bc.HumanHash(a)
 	expected := "6.29 kH/s"
 	if b != expected {
@@ -72,3 +72,13 @@ func testDifficulty(bits string, expected float64, t *testing.T) {
 		t.Errorf("Expected difficulty of '%s' to be '%v', got %v", bits, expected, d)
 	}
 }
+
+func TestCalcWork(t *testing.T) {
+	// the genesis block has 2^32 expected hashes of work.
+	if got := bc.CalcWork(0x1d00ffff).Uint64(); got != 4295032833 {
+		t.Errorf("Expected work of genesis bits to be 4295032833, got %d", got)
+	}
+	if got := bc.CalcWork(0x207fffff).Uint64(); got != 2 {
+		t.Errorf("Expected work of regtest bits to be 2, got %d", got)
+	}
+}
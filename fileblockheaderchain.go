@@ -0,0 +1,393 @@
+package bc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// blockHeaderLen is the length of a serialised block header and so of each
+// record in a FileBlockHeaderChain.
+const blockHeaderLen = 80
+
+var (
+	// ErrCorruptHeaderFile is returned when a header file contains a header which doesn't
+	// link to the one before it.
+	ErrCorruptHeaderFile = errors.New("header file is corrupt")
+	// ErrHeaderForksChain is returned when a header connects to a header below the tip of a
+	// FileBlockHeaderChain. The chain must be truncated to the fork point before it is added.
+	ErrHeaderForksChain = errors.New("header forks from the chain below its tip")
+)
+
+// A FileBlockHeaderChain is a BlockHeaderChain persisted in an append only file of
+// 80 byte header records, the record at offset (height-root)*80 being the header at
+// that height, where root is the height of the first header which is zero unless the
+// chain starts from a checkpoint.
+//
+// Only a single chain is stored so a reorg is handled by truncating the chain back
+// to the fork point with Truncate and then adding the headers of the new branch.
+type FileBlockHeaderChain struct {
+	mu     sync.RWMutex
+	file   *os.File
+	root   uint32
+	index  map[[32]byte]uint32
+	hashes [][32]byte
+	// tip is the last header, kept so that it can be returned without reading the file.
+	tip  *BlockHeader
+	opts *headerChainOptions
+}
+
+// OpenFileBlockHeaderChain opens the header file at path, creating it with the genesis
+// header of the network params provided if it doesn't exist.
+//
+// The hash to height index is rebuilt from the file as it is opened. A partially
+// written final record, as left by a crash part way through a write, is truncated
+// away. ErrCorruptHeaderFile is returned if the file doesn't start with the genesis
+// header or any header doesn't link to the one before it.
+//
+// opts control the validation applied to headers as they are added, by default
+// their proof of work, difficulty, checkpoints and timestamp are all checked.
+func OpenFileBlockHeaderChain(path string, params *ChainParams, opts ...HeaderChainOpt) (*FileBlockHeaderChain, error) {
+	return OpenFileBlockHeaderChainFromCheckpoint(path, params, 0, params.Genesis, opts...)
+}
+
+// OpenFileBlockHeaderChainFromCheckpoint opens the header file at path of a chain which
+// starts with the trusted header root at height rather than with genesis, creating it
+// if it doesn't exist. The same root must be provided each time the file is opened.
+//
+// The header is trusted as given, ErrCheckpointMismatch is only returned if it conflicts
+// with a checkpoint at its height. The difficulty of headers whose retarget depends on
+// headers before root can't be verified.
+func OpenFileBlockHeaderChainFromCheckpoint(path string, params *ChainParams, height uint32, root *BlockHeader,
+	opts ...HeaderChainOpt) (*FileBlockHeaderChain, error) {
+	if err := checkHeaderFields(root); err != nil {
+		return nil, err
+	}
+
+	o := newHeaderChainOptions(params, height, opts)
+	if err := o.checkCheckpoints(height, height, hex.EncodeToString(root.hash())); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FileBlockHeaderChain{
+		file:  f,
+		root:  height,
+		index: make(map[[32]byte]uint32),
+		opts:  o,
+	}
+	if err := c.load(root); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *FileBlockHeaderChain) load(root *BlockHeader) error {
+	info, err := c.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if torn := size % blockHeaderLen; torn != 0 {
+		size -= torn
+		if err := c.file.Truncate(size); err != nil {
+			return fmt.Errorf("failed to truncate torn header record: %w", err)
+		}
+	}
+
+	if size == 0 {
+		return c.append(root, root.hash())
+	}
+
+	b := make([]byte, size)
+	if _, err := c.file.ReadAt(b, 0); err != nil {
+		return err
+	}
+
+	c.hashes = make([][32]byte, 0, size/blockHeaderLen)
+	for offset := 0; offset < len(b); offset += blockHeaderLen {
+		bh, err := NewBlockHeaderFromBytes(b[offset : offset+blockHeaderLen])
+		if err != nil {
+			return err
+		}
+
+		height := c.root + uint32(len(c.hashes))
+		if height == c.root {
+			if !Equals(bh.bytes(), root.bytes()) {
+				return fmt.Errorf("%w: first header isn't the root header", ErrCorruptHeaderFile)
+			}
+		} else if !Equals(bh.HashPrevBlock, c.hashes[len(c.hashes)-1][:]) {
+			return fmt.Errorf("%w: header at height %d doesn't link to its previous header", ErrCorruptHeaderFile, height)
+		}
+
+		var hash [32]byte
+		copy(hash[:], bh.hash())
+		if err := c.opts.checkCheckpoints(height, height, hex.EncodeToString(hash[:])); err != nil {
+			return fmt.Errorf("%w: %s", ErrCorruptHeaderFile, err)
+		}
+		c.index[hash] = height
+		c.hashes = append(c.hashes, hash)
+		c.tip = bh
+	}
+
+	return nil
+}
+
+// Close syncs and closes the underlying header file.
+func (c *FileBlockHeaderChain) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.file.Sync(); err != nil {
+		return err
+	}
+
+	return c.file.Close()
+}
+
+// BlockHeader returns the header for the blockHash provided, or ErrHeaderNotFound if
+// it isn't in the chain.
+func (c *FileBlockHeaderChain) BlockHeader(ctx context.Context, blockHash string) (*BlockHeader, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	height, err := c.height(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.read(height)
+}
+
+// BlockHeight returns the height of the header for the blockHash provided, or
+// ErrHeaderNotFound if it isn't in the chain.
+func (c *FileBlockHeaderChain) BlockHeight(ctx context.Context, blockHash string) (uint32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.height(blockHash)
+}
+
+// AddHeader appends the header to the chain. The header must extend the current tip,
+// ErrHeaderForksChain is returned if it connects to an earlier header and ErrOrphanHeader
+// if it doesn't connect to the chain at all.
+//
+// Adding a header which is already in the chain has no effect.
+func (c *FileBlockHeaderChain) AddHeader(bh *BlockHeader) error {
+	if err := checkHeaderFields(bh); err != nil {
+		return err
+	}
+	if !bh.Valid() {
+		return ErrInvalidProofOfWork
+	}
+
+	var hash, prev [32]byte
+	copy(hash[:], bh.hash())
+	copy(prev[:], bh.HashPrevBlock)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.index[hash]; ok {
+		return nil
+	}
+
+	height, ok := c.index[prev]
+	if !ok {
+		return fmt.Errorf("%w: previous block %s", ErrOrphanHeader, bh.HashPrevBlockStr())
+	}
+	if tip := c.tipHeight(); height != tip {
+		if err := c.opts.checkCheckpoints(height+1, tip, hex.EncodeToString(hash[:])); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: previous block %s is at height %d", ErrHeaderForksChain, bh.HashPrevBlockStr(), height)
+	}
+
+	if err := c.opts.checkCheckpoints(height+1, height, hex.EncodeToString(hash[:])); err != nil {
+		return err
+	}
+	if err := c.opts.validate(&fileBranch{c: c}, bh); err != nil {
+		return err
+	}
+
+	return c.append(bh, hash[:])
+}
+
+// Truncate removes every header above height from the chain, so that the headers of a
+// branch forking at height can then be added. The root header cannot be removed, and
+// when checkpoints are verified ErrForkBelowCheckpoint is returned if height is below
+// the last checkpoint the chain has reached.
+func (c *FileBlockHeaderChain) Truncate(height uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tip := c.tipHeight()
+	if height >= tip {
+		return nil
+	}
+	if height < c.root {
+		height = c.root
+	}
+	if c.opts.verifyCheckpoints {
+		if last := LastCheckpoint(c.opts.checkpoints, tip); last != nil && height < last.Height {
+			return fmt.Errorf("%w: truncating to height %d, checkpoint at height %d", ErrForkBelowCheckpoint, height, last.Height)
+		}
+	}
+
+	bh, err := c.read(height)
+	if err != nil {
+		return err
+	}
+
+	n := height - c.root + 1
+	if err := c.file.Truncate(int64(n) * blockHeaderLen); err != nil {
+		return err
+	}
+
+	for _, hash := range c.hashes[n:] {
+		delete(c.index, hash)
+	}
+	c.hashes = c.hashes[:n]
+	c.tip = bh
+
+	return nil
+}
+
+// Tip returns the last header of the chain along with its height.
+func (c *FileBlockHeaderChain) Tip() (*BlockHeader, uint32) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tip, c.tipHeight()
+}
+
+// Height returns the height of the last header of the chain.
+func (c *FileBlockHeaderChain) Height() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tipHeight()
+}
+
+// HeaderAtHeight returns the header of the chain at the height provided.
+func (c *FileBlockHeaderChain) HeaderAtHeight(height uint32) (*BlockHeader, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return (&fileBranch{c: c}).HeaderAtHeight(height)
+}
+
+// HashAtHeight returns the block hash of the header of the chain at the height provided.
+func (c *FileBlockHeaderChain) HashAtHeight(height uint32) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.contains(height) {
+		return "", fmt.Errorf("%w: no header at height %d", ErrHeaderNotFound, height)
+	}
+
+	return hex.EncodeToString(c.hashes[height-c.root][:]), nil
+}
+
+// MedianTimePast returns the median time past of the header for the blockHash provided,
+// which is the median timestamp of that header and the ten before it.
+func (c *FileBlockHeaderChain) MedianTimePast(ctx context.Context, blockHash string) (uint32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	height, err := c.height(blockHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return MedianTimePast(&fileBranch{c: c}, height)
+}
+
+// height looks up the height of the header for blockHash, the caller must hold the lock.
+func (c *FileBlockHeaderChain) height(blockHash string) (uint32, error) {
+	hash, err := hex.DecodeString(blockHash)
+	if err != nil {
+		return 0, err
+	}
+	if len(hash) != 32 {
+		return 0, ErrHeaderNotFound
+	}
+
+	var key [32]byte
+	copy(key[:], hash)
+
+	height, ok := c.index[key]
+	if !ok {
+		return 0, ErrHeaderNotFound
+	}
+
+	return height, nil
+}
+
+// tipHeight returns the height of the last header, the caller must hold the lock.
+func (c *FileBlockHeaderChain) tipHeight() uint32 {
+	return c.root + uint32(len(c.hashes)) - 1
+}
+
+// contains reports whether there is a header at height, the caller must hold the lock.
+func (c *FileBlockHeaderChain) contains(height uint32) bool {
+	return height >= c.root && height <= c.tipHeight()
+}
+
+// read reads the header record at height, the caller must hold the lock.
+func (c *FileBlockHeaderChain) read(height uint32) (*BlockHeader, error) {
+	b := make([]byte, blockHeaderLen)
+	if _, err := c.file.ReadAt(b, int64(height-c.root)*blockHeaderLen); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: header at height %d is missing from file", ErrCorruptHeaderFile, height)
+		}
+		return nil, err
+	}
+
+	return NewBlockHeaderFromBytes(b)
+}
+
+// append writes the record of a header to the end of the file and indexes it, the
+// caller must hold the lock.
+func (c *FileBlockHeaderChain) append(bh *BlockHeader, hash []byte) error {
+	if _, err := c.file.WriteAt(bh.bytes(), int64(len(c.hashes))*blockHeaderLen); err != nil {
+		return err
+	}
+
+	var key [32]byte
+	copy(key[:], hash)
+	c.index[key] = c.root + uint32(len(c.hashes))
+	c.hashes = append(c.hashes, key)
+	c.tip = bh
+
+	return nil
+}
+
+// fileBranch is the HeaderBranch of the whole chain for use while the caller holds
+// the chain's lock.
+type fileBranch struct {
+	c *FileBlockHeaderChain
+}
+
+func (b *fileBranch) Height() uint32 {
+	return b.c.tipHeight()
+}
+
+func (b *fileBranch) HeaderAtHeight(height uint32) (*BlockHeader, error) {
+	if !b.c.contains(height) {
+		return nil, fmt.Errorf("%w: no header at height %d", ErrHeaderNotFound, height)
+	}
+
+	return b.c.read(height)
+}
@@ -0,0 +1,149 @@
+package bc_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestFileBlockHeaderChain_Reopen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	c, err := bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.NoError(t, err)
+
+	headers := mineBranch(t, regtestGenesis, 5, "main")
+	for _, bh := range headers {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+	assert.NoError(t, c.Close())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6*80), info.Size())
+
+	c, err = bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, uint32(5), c.Height())
+	tip, height := c.Tip()
+	assert.Equal(t, uint32(5), height)
+	assert.Equal(t, headers[4].String(), tip.String())
+
+	for i, bh := range headers {
+		got, err := c.BlockHeader(ctx, headerHashStr(bh))
+		assert.NoError(t, err)
+		assert.Equal(t, bh.String(), got.String())
+
+		hash, err := c.HashAtHeight(uint32(i + 1))
+		assert.NoError(t, err)
+		assert.Equal(t, headerHashStr(bh), hash)
+
+		height, err := c.BlockHeight(ctx, headerHashStr(bh))
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(i+1), height)
+	}
+
+	_, err = c.BlockHeader(ctx, "0000000000000000000000000000000000000000000000000000000000000000")
+	assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+}
+
+func TestFileBlockHeaderChain_TornRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	c, err := bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.NoError(t, err)
+	headers := mineBranch(t, regtestGenesis, 3, "main")
+	for _, bh := range headers {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+	assert.NoError(t, c.Close())
+
+	// simulate a crash part way through writing the next header.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.Write(headerBytes(t, mineHeader(t, headers[2], "torn"))[:37])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	c, err = bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, uint32(3), c.Height())
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4*80), info.Size())
+
+	assert.NoError(t, c.AddHeader(mineHeader(t, headers[2], "next")))
+	assert.Equal(t, uint32(4), c.Height())
+}
+
+func TestFileBlockHeaderChain_Corrupt(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	headers := mineBranch(t, regtestGenesis, 2, "main")
+	b := append(headerBytes(t, regtestGenesis), headerBytes(t, headers[1])...)
+	assert.NoError(t, os.WriteFile(path, b, 0o600))
+
+	_, err := bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.True(t, errors.Is(err, bc.ErrCorruptHeaderFile))
+
+	assert.NoError(t, os.WriteFile(path, headerBytes(t, headers[0]), 0o600))
+	_, err = bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.True(t, errors.Is(err, bc.ErrCorruptHeaderFile))
+}
+
+func TestFileBlockHeaderChain_Reorg(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	c, err := bc.OpenFileBlockHeaderChain(path, bc.RegTestParams)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	main := mineBranch(t, regtestGenesis, 4, "main")
+	for _, bh := range main {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+
+	fork := mineBranch(t, main[1], 3, "fork")
+	err = c.AddHeader(fork[0])
+	assert.True(t, errors.Is(err, bc.ErrHeaderForksChain))
+
+	err = c.AddHeader(fork[1])
+	assert.True(t, errors.Is(err, bc.ErrOrphanHeader))
+
+	assert.NoError(t, c.Truncate(2))
+	tip, height := c.Tip()
+	assert.Equal(t, uint32(2), height)
+	assert.Equal(t, main[1].String(), tip.String())
+
+	for _, bh := range fork {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+
+	assert.Equal(t, uint32(5), c.Height())
+	tip, height = c.Tip()
+	assert.Equal(t, uint32(5), height)
+	assert.Equal(t, fork[2].String(), tip.String())
+
+	_, err = c.BlockHeader(ctx, headerHashStr(main[2]))
+	assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+
+	bh, err := c.HeaderAtHeight(3)
+	assert.NoError(t, err)
+	assert.Equal(t, fork[0].String(), bh.String())
+}
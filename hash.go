@@ -0,0 +1,112 @@
+package bc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/libsv/go-bk/crypto"
+	"github.com/libsv/go-bt/v2"
+)
+
+// HashSize is the length in bytes of a Hash.
+const HashSize = 32
+
+// ErrInvalidHashLength is returned when a hash isn't 32 bytes long.
+var ErrInvalidHashLength = errors.New("hash should be 32 bytes")
+
+// A Hash is a double sha256 hash, such as a block hash, txid or merkle tree node.
+//
+// It is held in wire order, the order it is serialised in and in which it is the
+// output of the hash function. Hashes are displayed in the reverse, display, order,
+// so String, NewHashFromString and the text and JSON encodings all use display order
+// whereas Bytes and NewHashFromBytes use wire order.
+type Hash [HashSize]byte
+
+// NewHashFromBytes returns the Hash of the 32 bytes provided in wire order.
+func NewHashFromBytes(b []byte) (Hash, error) {
+	var h Hash
+	if len(b) != HashSize {
+		return h, fmt.Errorf("%w: got %d bytes", ErrInvalidHashLength, len(b))
+	}
+	copy(h[:], b)
+
+	return h, nil
+}
+
+// NewHashFromDisplayBytes returns the Hash of the 32 bytes provided in display order,
+// as held in the []byte fields of a BlockHeader.
+func NewHashFromDisplayBytes(b []byte) (Hash, error) {
+	if len(b) != HashSize {
+		return Hash{}, fmt.Errorf("%w: got %d bytes", ErrInvalidHashLength, len(b))
+	}
+
+	return NewHashFromBytes(bt.ReverseBytes(b))
+}
+
+// NewHashFromString returns the Hash of the hex string provided in display order.
+func NewHashFromString(s string) (Hash, error) {
+	var h Hash
+	if err := h.UnmarshalText([]byte(s)); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+// Sha256dHash returns the double sha256 Hash of b.
+func Sha256dHash(b []byte) Hash {
+	var h Hash
+	copy(h[:], crypto.Sha256d(b))
+
+	return h
+}
+
+// String returns the hash as a hex string in display order.
+func (h Hash) String() string {
+	return hex.EncodeToString(h.DisplayBytes())
+}
+
+// Bytes returns a copy of the hash in wire order.
+func (h Hash) Bytes() []byte {
+	b := make([]byte, HashSize)
+	copy(b, h[:])
+
+	return b
+}
+
+// DisplayBytes returns a copy of the hash in display order.
+func (h Hash) DisplayBytes() []byte {
+	return bt.ReverseBytes(h[:])
+}
+
+// IsZero reports whether every byte of the hash is zero, as is the previous block of
+// the genesis header.
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface, encoding the hash as
+// a hex string in display order.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface, decoding a hex
+// string in display order into the hash.
+func (h *Hash) UnmarshalText(text []byte) error {
+	if len(text) != HashSize*2 {
+		return fmt.Errorf("%w: got %d hex characters", ErrInvalidHashLength, len(text))
+	}
+
+	b := make([]byte, HashSize)
+	if _, err := hex.Decode(b, text); err != nil {
+		return err
+	}
+
+	for i := 0; i < HashSize; i++ {
+		h[i] = b[HashSize-1-i]
+	}
+
+	return nil
+}
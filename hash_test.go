@@ -0,0 +1,92 @@
+package bc_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestHash_ByteOrder(t *testing.T) {
+	t.Parallel()
+
+	const display = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	h, err := bc.NewHashFromString(display)
+	assert.NoError(t, err)
+	assert.Equal(t, display, h.String())
+	assert.Equal(t, display, hex.EncodeToString(h.DisplayBytes()))
+	assert.Equal(t, "6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000", hex.EncodeToString(h.Bytes()))
+
+	fromWire, err := bc.NewHashFromBytes(h.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, h, fromWire)
+
+	fromDisplay, err := bc.NewHashFromDisplayBytes(h.DisplayBytes())
+	assert.NoError(t, err)
+	assert.Equal(t, h, fromDisplay)
+
+	genesis, err := bc.MainNetParams.Genesis.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, h, bc.Sha256dHash(genesis))
+	assert.False(t, h.IsZero())
+	assert.True(t, bc.Hash{}.IsZero())
+}
+
+func TestHash_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hash   string
+		expErr error
+	}{
+		"too short": {
+			hash:   "0019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			expErr: bc.ErrInvalidHashLength,
+		},
+		"too long": {
+			hash:   "00000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			expErr: bc.ErrInvalidHashLength,
+		},
+		"not hex": {
+			hash: "zz0000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bc.NewHashFromString(test.hash)
+			assert.Error(t, err)
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr))
+			}
+		})
+	}
+
+	_, err := bc.NewHashFromBytes(make([]byte, 31))
+	assert.True(t, errors.Is(err, bc.ErrInvalidHashLength))
+}
+
+func TestHash_JSON(t *testing.T) {
+	t.Parallel()
+
+	type wrapper struct {
+		Hash bc.Hash `json:"hash"`
+	}
+
+	h, err := bc.NewHashFromString("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(wrapper{Hash: h})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"hash":"000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"}`, string(b))
+
+	var w wrapper
+	assert.NoError(t, json.Unmarshal(b, &w))
+	assert.Equal(t, h, w.Hash)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"hash":"00"}`), &w))
+}
@@ -0,0 +1,73 @@
+package bc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultMaxFutureBlockTime is the furthest a header's timestamp may be ahead of the
+// current time before a node rejects it.
+const DefaultMaxFutureBlockTime = 2 * time.Hour
+
+// medianTimeSpan is the number of headers the median time past is taken over.
+const medianTimeSpan = 11
+
+var (
+	// ErrHeaderTimeTooOld is returned when a header's timestamp isn't after the median
+	// time past of its previous headers.
+	ErrHeaderTimeTooOld = errors.New("header time is not after median time past")
+	// ErrHeaderTimeTooNew is returned when a header's timestamp is further in the future
+	// than the maximum allowed drift.
+	ErrHeaderTimeTooNew = errors.New("header time is too far in the future")
+)
+
+// MedianTimePast returns the median timestamp of the header of the branch at height
+// and the ten headers before it, or of as many as there are when the branch starts
+// less than ten headers before height.
+//
+// The median time past is used in place of the header time when evaluating nLockTime
+// and nSequence finality, since it can't be moved backwards by a single miner.
+func MedianTimePast(branch HeaderBranch, height uint32) (uint32, error) {
+	times := make([]uint32, 0, medianTimeSpan)
+	for i := 0; i < medianTimeSpan; i++ {
+		header, err := branch.HeaderAtHeight(height)
+		if err != nil {
+			// The branch starts after genesis, as when a chain starts from a
+			// checkpoint, so use the headers it has.
+			if len(times) > 0 && errors.Is(err, ErrHeaderNotFound) {
+				break
+			}
+			return 0, err
+		}
+		times = append(times, header.Time)
+
+		if height == 0 {
+			break
+		}
+		height--
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2], nil
+}
+
+// CheckHeaderTime validates the timestamp of a header which extends the branch
+// provided. ErrHeaderTimeTooOld is returned if it isn't after the median time past
+// of the branch and ErrHeaderTimeTooNew if it is more than maxFutureDrift after now.
+func CheckHeaderTime(branch HeaderBranch, bh *BlockHeader, now time.Time, maxFutureDrift time.Duration) error {
+	mtp, err := MedianTimePast(branch, branch.Height())
+	if err != nil {
+		return err
+	}
+	if bh.Time <= mtp {
+		return fmt.Errorf("%w: time %d, median time past %d", ErrHeaderTimeTooOld, bh.Time, mtp)
+	}
+
+	if limit := now.Add(maxFutureDrift).Unix(); int64(bh.Time) > limit {
+		return fmt.Errorf("%w: time %d, limit %d", ErrHeaderTimeTooNew, bh.Time, limit)
+	}
+
+	return nil
+}
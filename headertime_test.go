@@ -0,0 +1,139 @@
+package bc_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestMedianTimePast(t *testing.T) {
+	t.Parallel()
+
+	times := []uint32{10, 40, 20, 30, 90, 50, 60, 80, 70, 100, 110, 0, 120}
+	branch := &bc.HeaderSequence{}
+	for _, tm := range times {
+		branch.Headers = append(branch.Headers, headerWithBits(0x207fffff, tm))
+	}
+
+	tests := map[string]struct {
+		height uint32
+		expMTP uint32
+	}{
+		"genesis is its own median": {
+			height: 0,
+			expMTP: 10,
+		},
+		"fewer than 11 headers uses those available": {
+			height: 3,
+			expMTP: 30,
+		},
+		"median of 11 headers": {
+			height: 10,
+			expMTP: 60,
+		},
+		"only the last 11 headers are used": {
+			height: 12,
+			expMTP: 70,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mtp, err := bc.MedianTimePast(branch, test.height)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expMTP, mtp)
+		})
+	}
+}
+
+func TestCheckHeaderTime(t *testing.T) {
+	t.Parallel()
+
+	branch := headerSequence(20, 0x207fffff, func(h int) uint32 { return 1600000000 + uint32(h)*600 })
+	mtp, err := bc.MedianTimePast(branch, branch.Height())
+	assert.NoError(t, err)
+	now := time.Unix(int64(mtp)+3600, 0)
+
+	tests := map[string]struct {
+		time   uint32
+		expErr error
+	}{
+		"time after median time past is valid": {
+			time: mtp + 1,
+		},
+		"time equal to median time past is rejected": {
+			time:   mtp,
+			expErr: bc.ErrHeaderTimeTooOld,
+		},
+		"time at the drift limit is valid": {
+			time: uint32(now.Add(bc.DefaultMaxFutureBlockTime).Unix()),
+		},
+		"time beyond the drift limit is rejected": {
+			time:   uint32(now.Add(bc.DefaultMaxFutureBlockTime).Unix()) + 1,
+			expErr: bc.ErrHeaderTimeTooNew,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := bc.CheckHeaderTime(branch, headerWithBits(0x207fffff, test.time), now, bc.DefaultMaxFutureBlockTime)
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBlockHeaderChain_VerifyTime(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	headers := mineBranch(t, regtestGenesis, 12, "main")
+	now := func() time.Time {
+		return time.Unix(int64(headers[11].Time)+60, 0)
+	}
+	opts := []bc.HeaderChainOpt{bc.VerifyTime(time.Hour), bc.TimeSource(now)}
+
+	mc, err := bc.NewMemoryBlockHeaderChain(bc.RegTestParams, opts...)
+	assert.NoError(t, err)
+	fc, err := bc.OpenFileBlockHeaderChain(filepath.Join(t.TempDir(), "headers.dat"), bc.RegTestParams, opts...)
+	assert.NoError(t, err)
+	defer fc.Close()
+
+	for _, c := range []interface {
+		bc.MedianTimePastChain
+		AddHeader(*bc.BlockHeader) error
+	}{mc, fc} {
+		for _, bh := range headers {
+			assert.NoError(t, c.AddHeader(bh))
+		}
+
+		mtp, err := c.MedianTimePast(ctx, headerHashStr(headers[11]))
+		assert.NoError(t, err)
+		assert.Equal(t, headers[6].Time, mtp)
+
+		old := mineHeader(t, headers[11], "old")
+		old.Time = mtp
+		for !old.Valid() {
+			old.Nonce++
+		}
+		assert.True(t, errors.Is(c.AddHeader(old), bc.ErrHeaderTimeTooOld))
+
+		future := mineHeader(t, headers[11], "future")
+		future.Time = uint32(now().Add(time.Hour).Unix()) + 1
+		for !future.Valid() {
+			future.Nonce++
+		}
+		assert.True(t, errors.Is(c.AddHeader(future), bc.ErrHeaderTimeTooNew))
+
+		assert.NoError(t, c.AddHeader(mineHeader(t, headers[11], "next")))
+	}
+}
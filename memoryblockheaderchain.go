@@ -0,0 +1,360 @@
+package bc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// A ChainTip describes the last header of a branch in a block header chain.
+type ChainTip struct {
+	Hash      string   `json:"hash"`
+	Height    uint32   `json:"height"`
+	ChainWork *big.Int `json:"chainwork"`
+	// BranchLen is the number of headers between the tip and the point at
+	// which it forked from the active chain. It is zero for the active tip.
+	BranchLen uint32 `json:"branchlen"`
+	Active    bool   `json:"active"`
+}
+
+// headerNode links a header to its parent and records its position and the
+// total work of the branch ending with it.
+type headerNode struct {
+	header    *BlockHeader
+	hash      string
+	height    uint32
+	chainWork *big.Int
+	parent    *headerNode
+}
+
+// A MemoryBlockHeaderChain is a concurrency safe, in memory, implementation of
+// BlockHeaderChain.
+//
+// Headers are linked together by HashPrevBlock and every branch is kept, so
+// that when a branch accumulates more work than the active chain it becomes
+// the active chain.
+type MemoryBlockHeaderChain struct {
+	mu     sync.RWMutex
+	nodes  map[string]*headerNode
+	tips   map[string]*headerNode
+	active []*headerNode
+	opts   *headerChainOptions
+}
+
+// NewMemoryBlockHeaderChain returns a MemoryBlockHeaderChain for the network params
+// provided, starting with its genesis header.
+//
+// opts control the validation applied to headers as they are added, by default
+// their proof of work, difficulty, checkpoints and timestamp are all checked.
+func NewMemoryBlockHeaderChain(params *ChainParams, opts ...HeaderChainOpt) (*MemoryBlockHeaderChain, error) {
+	return NewMemoryBlockHeaderChainFromCheckpoint(params, 0, params.Genesis, opts...)
+}
+
+// NewMemoryBlockHeaderChainFromCheckpoint returns a MemoryBlockHeaderChain for the
+// network params provided which starts with the trusted header root at height, rather
+// than with genesis, so that the headers before it needn't be synced.
+//
+// The header is trusted as given, ErrCheckpointMismatch is only returned if it conflicts
+// with a checkpoint at its height. The chain work of each header is counted from root,
+// and the difficulty of headers whose retarget depends on headers before root can't
+// be verified.
+func NewMemoryBlockHeaderChainFromCheckpoint(params *ChainParams, height uint32, root *BlockHeader,
+	opts ...HeaderChainOpt) (*MemoryBlockHeaderChain, error) {
+	if err := checkHeaderFields(root); err != nil {
+		return nil, err
+	}
+
+	o := newHeaderChainOptions(params, height, opts)
+	bh := root.clone()
+	n := &headerNode{
+		header:    bh,
+		hash:      hex.EncodeToString(bh.hash()),
+		height:    height,
+		chainWork: CalcWork(headerBits(bh)),
+	}
+	if err := o.checkCheckpoints(n.height, n.height, n.hash); err != nil {
+		return nil, err
+	}
+
+	return &MemoryBlockHeaderChain{
+		nodes:  map[string]*headerNode{n.hash: n},
+		tips:   map[string]*headerNode{n.hash: n},
+		active: []*headerNode{n},
+		opts:   o,
+	}, nil
+}
+
+// BlockHeader returns the header for the blockHash provided. ErrHeaderNotFound is
+// returned if the header isn't known and ErrNotOnLongestChain if it is known but
+// is on a branch other than the active chain.
+func (c *MemoryBlockHeaderChain) BlockHeader(ctx context.Context, blockHash string) (*BlockHeader, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, ok := c.nodes[blockHash]
+	if !ok {
+		return nil, ErrHeaderNotFound
+	}
+	if !c.isActive(n) {
+		return nil, ErrNotOnLongestChain
+	}
+
+	return n.header, nil
+}
+
+// BlockHeight returns the height of the header for the blockHash provided, with the
+// same errors as BlockHeader.
+func (c *MemoryBlockHeaderChain) BlockHeight(ctx context.Context, blockHash string) (uint32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, ok := c.nodes[blockHash]
+	if !ok {
+		return 0, ErrHeaderNotFound
+	}
+	if !c.isActive(n) {
+		return 0, ErrNotOnLongestChain
+	}
+
+	return n.height, nil
+}
+
+// AddHeader links the header to its parent. If the branch it extends then has
+// more work than the active chain, that branch becomes the active chain.
+//
+// Adding a header which is already known has no effect. ErrOrphanHeader is
+// returned if the previous block is not known, otherwise the header is validated
+// against the checkpoints and the branch it extends.
+func (c *MemoryBlockHeaderChain) AddHeader(bh *BlockHeader) error {
+	if err := checkHeaderFields(bh); err != nil {
+		return err
+	}
+	if !bh.Valid() {
+		return ErrInvalidProofOfWork
+	}
+
+	bh = bh.clone()
+	hash := hex.EncodeToString(bh.hash())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.nodes[hash]; ok {
+		return nil
+	}
+
+	parent, ok := c.nodes[bh.HashPrevBlockStr()]
+	if !ok {
+		return fmt.Errorf("%w: previous block %s", ErrOrphanHeader, bh.HashPrevBlockStr())
+	}
+
+	if err := c.opts.checkCheckpoints(parent.height+1, c.tip().height, hash); err != nil {
+		return err
+	}
+	if err := c.opts.validate(&nodeBranch{c: c, tip: parent}, bh); err != nil {
+		return err
+	}
+
+	n := &headerNode{
+		header:    bh,
+		hash:      hash,
+		height:    parent.height + 1,
+		chainWork: new(big.Int).Add(parent.chainWork, CalcWork(headerBits(bh))),
+		parent:    parent,
+	}
+	c.nodes[hash] = n
+	delete(c.tips, parent.hash)
+	c.tips[hash] = n
+
+	// A branch only replaces the active chain once it has strictly more work,
+	// so the first seen of two equal branches stays active.
+	if n.chainWork.Cmp(c.tip().chainWork) > 0 {
+		c.activate(n)
+	}
+
+	return nil
+}
+
+// Tip returns the last header of the active chain along with its height.
+func (c *MemoryBlockHeaderChain) Tip() (*BlockHeader, uint32) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := c.tip()
+	return n.header, n.height
+}
+
+// Height returns the height of the last header of the active chain.
+func (c *MemoryBlockHeaderChain) Height() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tip().height
+}
+
+// Tips returns the tip of every branch known to the chain, the active tip first
+// followed by the other branches ordered by descending chain work.
+func (c *MemoryBlockHeaderChain) Tips() []ChainTip {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tips := make([]ChainTip, 0, len(c.tips))
+	for _, n := range c.tips {
+		tip := ChainTip{
+			Hash:      n.hash,
+			Height:    n.height,
+			ChainWork: new(big.Int).Set(n.chainWork),
+			Active:    c.isActive(n),
+		}
+		for f := n; !c.isActive(f); f = f.parent {
+			tip.BranchLen++
+		}
+		tips = append(tips, tip)
+	}
+
+	sort.SliceStable(tips, func(i, j int) bool {
+		if tips[i].Active != tips[j].Active {
+			return tips[i].Active
+		}
+		if cmp := tips[i].ChainWork.Cmp(tips[j].ChainWork); cmp != 0 {
+			return cmp > 0
+		}
+		return tips[i].Hash < tips[j].Hash
+	})
+
+	return tips
+}
+
+// HeaderAtHeight returns the header of the active chain at the height provided.
+func (c *MemoryBlockHeaderChain) HeaderAtHeight(height uint32) (*BlockHeader, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, err := c.nodeAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.header, nil
+}
+
+// HashAtHeight returns the block hash of the header of the active chain at the
+// height provided.
+func (c *MemoryBlockHeaderChain) HashAtHeight(height uint32) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, err := c.nodeAtHeight(height)
+	if err != nil {
+		return "", err
+	}
+
+	return n.hash, nil
+}
+
+// ChainWork returns the cumulative work of the branch ending with the header of
+// the blockHash provided.
+func (c *MemoryBlockHeaderChain) ChainWork(blockHash string) (*big.Int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, ok := c.nodes[blockHash]
+	if !ok {
+		return nil, ErrHeaderNotFound
+	}
+
+	return new(big.Int).Set(n.chainWork), nil
+}
+
+// MedianTimePast returns the median time past of the header for the blockHash provided,
+// which is the median timestamp of that header and the ten before it.
+func (c *MemoryBlockHeaderChain) MedianTimePast(ctx context.Context, blockHash string) (uint32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n, ok := c.nodes[blockHash]
+	if !ok {
+		return 0, ErrHeaderNotFound
+	}
+
+	return MedianTimePast(&nodeBranch{c: c, tip: n}, n.height)
+}
+
+func (c *MemoryBlockHeaderChain) tip() *headerNode {
+	return c.active[len(c.active)-1]
+}
+
+func (c *MemoryBlockHeaderChain) nodeAtHeight(height uint32) (*headerNode, error) {
+	n := c.activeAt(height)
+	if n == nil {
+		return nil, fmt.Errorf("%w: no header at height %d", ErrHeaderNotFound, height)
+	}
+
+	return n, nil
+}
+
+// activeAt returns the node of the active chain at height, or nil if there isn't one.
+func (c *MemoryBlockHeaderChain) activeAt(height uint32) *headerNode {
+	root := c.active[0].height
+	if height < root || int(height-root) >= len(c.active) {
+		return nil
+	}
+
+	return c.active[height-root]
+}
+
+func (c *MemoryBlockHeaderChain) isActive(n *headerNode) bool {
+	return c.activeAt(n.height) == n
+}
+
+// activate makes the branch ending in n the active chain by unwinding the active
+// chain back to the fork point and then appending the new branch.
+func (c *MemoryBlockHeaderChain) activate(n *headerNode) {
+	var branch []*headerNode
+	for f := n; !c.isActive(f); f = f.parent {
+		branch = append(branch, f)
+	}
+
+	forkHeight := n.height - uint32(len(branch))
+	c.active = c.active[:forkHeight-c.active[0].height+1]
+	for i := len(branch) - 1; i >= 0; i-- {
+		c.active = append(c.active, branch[i])
+	}
+}
+
+// nodeBranch is the HeaderBranch ending with tip, which may be on any branch of
+// the chain. The caller must hold the chain's lock while it is used.
+type nodeBranch struct {
+	c   *MemoryBlockHeaderChain
+	tip *headerNode
+}
+
+func (b *nodeBranch) Height() uint32 {
+	return b.tip.height
+}
+
+func (b *nodeBranch) HeaderAtHeight(height uint32) (*BlockHeader, error) {
+	if height > b.tip.height {
+		return nil, fmt.Errorf("%w: no header at height %d", ErrHeaderNotFound, height)
+	}
+
+	// Walk back until joining the active chain, after which the ancestor can be
+	// looked up by height.
+	n := b.tip
+	for n.height > height && !b.c.isActive(n) {
+		n = n.parent
+	}
+	if n.height == height {
+		return n.header, nil
+	}
+
+	n, err := b.c.nodeAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.header, nil
+}
@@ -0,0 +1,209 @@
+package bc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+var regtestGenesis, _ = bc.NewBlockHeaderFromStr("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff7f2002000000")
+
+// mineHeader builds a header extending prev and grinds the nonce until it satisfies its bits.
+// The tag is mixed into the merkle root so that siblings of the same parent differ.
+func mineHeader(t testing.TB, prev *bc.BlockHeader, tag string) *bc.BlockHeader {
+	t.Helper()
+
+	root := sha256.Sum256([]byte(tag))
+	bh := &bc.BlockHeader{
+		Version:        0x20000000,
+		Time:           prev.Time + 600,
+		HashPrevBlock:  headerHash(prev),
+		HashMerkleRoot: root[:],
+		Bits:           append([]byte{}, prev.Bits...),
+	}
+	for !bh.Valid() {
+		bh.Nonce++
+	}
+
+	return bh
+}
+
+func headerHash(bh *bc.BlockHeader) []byte {
+	h, _ := bh.Hash()
+	return h.DisplayBytes()
+}
+
+func headerBytes(t testing.TB, bh *bc.BlockHeader) []byte {
+	t.Helper()
+
+	b, err := bh.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func headerHashStr(bh *bc.BlockHeader) string {
+	return hex.EncodeToString(headerHash(bh))
+}
+
+// mineBranch mines count headers on top of prev.
+func mineBranch(t testing.TB, prev *bc.BlockHeader, count int, tag string) []*bc.BlockHeader {
+	t.Helper()
+
+	headers := make([]*bc.BlockHeader, 0, count)
+	for i := 0; i < count; i++ {
+		prev = mineHeader(t, prev, tag+string(rune('a'+i)))
+		headers = append(headers, prev)
+	}
+
+	return headers
+}
+
+func TestMemoryBlockHeaderChain_AddHeader(t *testing.T) {
+	t.Parallel()
+
+	main := mineBranch(t, regtestGenesis, 5, "main")
+
+	tests := map[string]struct {
+		header func() *bc.BlockHeader
+		expErr error
+	}{
+		"header extending the tip is added": {
+			header: func() *bc.BlockHeader {
+				return mineHeader(t, main[4], "next")
+			},
+		},
+		"duplicate header is ignored": {
+			header: func() *bc.BlockHeader {
+				return main[2]
+			},
+		},
+		"header with unknown parent is rejected": {
+			header: func() *bc.BlockHeader {
+				unknown := mineHeader(t, regtestGenesis, "unknown")
+				return mineHeader(t, unknown, "orphan")
+			},
+			expErr: bc.ErrOrphanHeader,
+		},
+		"header failing proof of work is rejected": {
+			header: func() *bc.BlockHeader {
+				bh := mineHeader(t, main[4], "bad pow")
+				for bh.Valid() {
+					bh.Nonce++
+				}
+				return bh
+			},
+			expErr: bc.ErrInvalidProofOfWork,
+		},
+		"header with short bits is rejected": {
+			header: func() *bc.BlockHeader {
+				bh := mineHeader(t, main[4], "short bits")
+				bh.Bits = bh.Bits[:3]
+				return bh
+			},
+			expErr: bc.ErrMalformedHeader,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := bc.NewMemoryBlockHeaderChain(bc.RegTestParams)
+			assert.NoError(t, err)
+			for _, bh := range main {
+				assert.NoError(t, c.AddHeader(bh))
+			}
+
+			err = c.AddHeader(test.header())
+			if test.expErr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, test.expErr))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMemoryBlockHeaderChain_Reorg(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	c, err := bc.NewMemoryBlockHeaderChain(bc.RegTestParams)
+	assert.NoError(t, err)
+
+	main := mineBranch(t, regtestGenesis, 4, "main")
+	for _, bh := range main {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+	assert.Equal(t, uint32(4), c.Height())
+
+	// a fork from height 2 of equal length doesn't replace the first seen branch.
+	fork := mineBranch(t, main[1], 2, "fork")
+	for _, bh := range fork {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+	tip, height := c.Tip()
+	assert.Equal(t, main[3].String(), tip.String())
+	assert.Equal(t, uint32(4), height)
+
+	_, err = c.BlockHeader(ctx, headerHashStr(fork[0]))
+	assert.True(t, errors.Is(err, bc.ErrNotOnLongestChain))
+
+	// extending the fork gives it more work so it becomes active.
+	fork = append(fork, mineHeader(t, fork[1], "fork tip"))
+	assert.NoError(t, c.AddHeader(fork[2]))
+
+	tip, height = c.Tip()
+	assert.Equal(t, fork[2].String(), tip.String())
+	assert.Equal(t, uint32(5), height)
+
+	bh, err := c.BlockHeader(ctx, headerHashStr(fork[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, fork[0].String(), bh.String())
+
+	_, err = c.BlockHeader(ctx, headerHashStr(main[3]))
+	assert.True(t, errors.Is(err, bc.ErrNotOnLongestChain))
+
+	height, err = c.BlockHeight(ctx, headerHashStr(fork[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), height)
+
+	_, err = c.BlockHeight(ctx, headerHashStr(main[3]))
+	assert.True(t, errors.Is(err, bc.ErrNotOnLongestChain))
+
+	_, err = c.BlockHeader(ctx, "0000000000000000000000000000000000000000000000000000000000000000")
+	assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+
+	hash, err := c.HashAtHeight(3)
+	assert.NoError(t, err)
+	assert.Equal(t, headerHashStr(fork[0]), hash)
+
+	bh, err = c.HeaderAtHeight(2)
+	assert.NoError(t, err)
+	assert.Equal(t, main[1].String(), bh.String())
+
+	_, err = c.HeaderAtHeight(6)
+	assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+
+	tips := c.Tips()
+	assert.Len(t, tips, 2)
+	assert.True(t, tips[0].Active)
+	assert.Equal(t, headerHashStr(fork[2]), tips[0].Hash)
+	assert.Equal(t, uint32(0), tips[0].BranchLen)
+	assert.False(t, tips[1].Active)
+	assert.Equal(t, headerHashStr(main[3]), tips[1].Hash)
+	assert.Equal(t, uint32(2), tips[1].BranchLen)
+
+	work := bc.CalcWork(binary.BigEndian.Uint32(regtestGenesis.Bits))
+	assert.Equal(t, work.Int64()*6, tips[0].ChainWork.Int64())
+	assert.Equal(t, work.Int64()*5, tips[1].ChainWork.Int64())
+}
@@ -92,3 +92,24 @@ func MerkleRootFromBranches(txHash string, txIndex int, branches []string) (stri
 	return hex.EncodeToString(bt.ReverseBytes(hash)), nil
 
 }
+
+// TypedMerkleRootFromBranches returns a Merkle root given a transaction hash (txid), the index in
+// which it is positioned in the Merkle tree, and the branches needed along the way (Merkle path).
+func TypedMerkleRootFromBranches(txHash Hash, txIndex int, branches []Hash) (Hash, error) {
+	hash := txHash
+	for _, b := range branches {
+		if txIndex&1 > 0 {
+			hash = TypedMerkleTreeParent(b, hash)
+		} else {
+			hash = TypedMerkleTreeParent(hash, b)
+		}
+
+		txIndex >>= 1
+	}
+
+	if txIndex > 0 {
+		return Hash{}, fmt.Errorf("index %d out of range for proof of length %d", txIndex, len(branches))
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,617 @@
+package bc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/libsv/go-bt/v2"
+)
+
+// The flags of a leaf of a MerklePath.
+const (
+	pathLeafHash      byte = 0 // a hash follows
+	pathLeafDuplicate byte = 1 // no hash follows as it duplicates the working hash
+	pathLeafTxID      byte = 2 // a hash follows which is a txid of interest
+)
+
+// maxPathHeight is the height of a merkle tree of the most transactions an offset
+// can address.
+const maxPathHeight = 64
+
+var (
+	// ErrMerklePathTruncated is returned when a binary merkle path ends before all of
+	// its fields have been read.
+	ErrMerklePathTruncated = errors.New("merkle path is truncated")
+	// ErrInvalidMerklePath is returned when a merkle path is malformed or doesn't hold
+	// the leaves needed to calculate its root.
+	ErrInvalidMerklePath = errors.New("invalid merkle path")
+	// ErrTxNotInMerklePath is returned when the root is requested for a txid which
+	// isn't at the bottom of a merkle path.
+	ErrTxNotInMerklePath = errors.New("tx not in merkle path")
+)
+
+// A MerklePath is a BSV Unified Merkle Path (BUMP) which proves the inclusion of one or
+// more transactions in the block at BlockHeight.
+//
+// Path holds a level of the merkle tree for each of its levels below the root, each
+// level holding the leaves needed to calculate the root from the txids at the bottom
+// level.
+//
+// See https://github.com/bitcoin-sv/BRCs/blob/master/transactions/0074.md
+type MerklePath struct {
+	BlockHeight uint64             `json:"blockHeight"`
+	Path        [][]MerklePathLeaf `json:"path"`
+}
+
+// A MerklePathLeaf is a node of a merkle tree held by a MerklePath at Offset within its
+// level. Hash is nil when the leaf is a Duplicate of its sibling, found at the end of a
+// level with an odd number of nodes. TxID is set on the txids of interest at the bottom
+// of the path.
+type MerklePathLeaf struct {
+	Offset    uint64 `json:"offset"`
+	Hash      *Hash  `json:"hash,omitempty"`
+	TxID      bool   `json:"txid,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// NewMerklePathFromStr returns the MerklePath of the hex string of its binary encoding.
+func NewMerklePathFromStr(s string) (*MerklePath, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMerklePathFromBytes(b)
+}
+
+// NewMerklePathFromBytes returns the MerklePath of its binary encoding.
+func NewMerklePathFromBytes(b []byte) (*MerklePath, error) {
+	var mp MerklePath
+	if err := mp.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	return &mp, nil
+}
+
+// NewMerklePathFromMerkleProof returns the MerklePath of the transactions proven by
+// proof, which is in the block at blockHeight. Branch, tree and composite proofs are
+// supported.
+//
+// The conversion is lossless other than the target of the proof, which is replaced by
+// blockHeight, so MerkleProof returns the same nodes for each of the transactions.
+func NewMerklePathFromMerkleProof(proof *MerkleProof, blockHeight uint64) (*MerklePath, error) {
+	if _, _, err := proof.CalculateRoot(); err != nil {
+		return nil, err
+	}
+
+	leaves, err := proof.leaves()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := proofNodes(proof.Nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newPathBuilder()
+	add := func(height int, pos uint64, node *Hash) {
+		b.add(height, MerklePathLeaf{Offset: pos, Hash: node, Duplicate: node == nil})
+	}
+
+	var height int
+	if proof.ProofType == "tree" {
+		if _, _, height, err = treeRoot(leaves, nodes, add); err != nil {
+			return nil, err
+		}
+	} else {
+		height = len(nodes) / len(leaves)
+		for i, l := range leaves {
+			for h, node := range nodes[i*height : (i+1)*height] {
+				add(h, (l.pos>>h)^1, node)
+			}
+		}
+	}
+
+	for _, l := range leaves {
+		hash := l.hash
+		b.add(0, MerklePathLeaf{Offset: l.pos, Hash: &hash, TxID: true})
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// a block of one transaction has a path of only its txid.
+	if height == 0 {
+		height = 1
+	}
+
+	return b.path(blockHeight, height), nil
+}
+
+// Bytes returns the binary encoding of the merkle path:
+//
+// blockHeight: varint,
+// treeHeight:  byte,
+// levels:      {nLeaves: varint, leaves: {offset: varint, flags: byte, hash: byte[32]}[nLeaves]}[treeHeight]
+//
+// where the hash of a leaf, in wire order, is omitted when its flags mark it as a
+// duplicate.
+func (mp *MerklePath) Bytes() ([]byte, error) {
+	if len(mp.Path) == 0 || len(mp.Path) > maxPathHeight {
+		return nil, fmt.Errorf("%w: tree height %d", ErrInvalidMerklePath, len(mp.Path))
+	}
+
+	b := bt.VarInt(mp.BlockHeight).Bytes()
+	b = append(b, byte(len(mp.Path)))
+	for height, level := range mp.Path {
+		b = append(b, bt.VarInt(uint64(len(level))).Bytes()...)
+		for _, leaf := range level {
+			flags, err := leaf.flags()
+			if err != nil {
+				return nil, fmt.Errorf("level %d offset %d: %w", height, leaf.Offset, err)
+			}
+
+			b = append(b, bt.VarInt(leaf.Offset).Bytes()...)
+			b = append(b, flags)
+			if flags != pathLeafDuplicate {
+				b = append(b, leaf.Hash[:]...)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// String returns the merkle path as the hex string of its binary encoding, or an empty
+// string if it is malformed.
+func (mp *MerklePath) String() string {
+	b, err := mp.Bytes()
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, encoding the path as
+// described by Bytes.
+func (mp *MerklePath) MarshalBinary() ([]byte, error) {
+	return mp.Bytes()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface, decoding the
+// path from the encoding described by Bytes.
+//
+// ErrMerklePathTruncated is returned if b ends early, and ErrInvalidMerklePath if a
+// leaf has unknown flags, an offset appears twice in a level, or anything follows the
+// last level.
+func (mp *MerklePath) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := mp.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %d unexpected bytes after last level", ErrInvalidMerklePath, r.Len())
+	}
+
+	return nil
+}
+
+// ReadFrom reads a binary merkle path from r into the receiving MerklePath, stopping
+// after its last level. It is used where a path is followed by other data, as in BEEF.
+//
+// ErrMerklePathTruncated is returned if r ends early, and ErrInvalidMerklePath if a
+// leaf has unknown flags or an offset appears twice in a level.
+func (mp *MerklePath) ReadFrom(r io.Reader) (int64, error) {
+	pr := &pathReader{r: r}
+
+	blockHeight, err := pr.varInt("block height")
+	if err != nil {
+		return pr.n, err
+	}
+
+	treeHeight, err := pr.byte("tree height")
+	if err != nil {
+		return pr.n, err
+	}
+	if treeHeight == 0 || treeHeight > maxPathHeight {
+		return pr.n, fmt.Errorf("%w: tree height %d", ErrInvalidMerklePath, treeHeight)
+	}
+
+	path := make([][]MerklePathLeaf, treeHeight)
+	for height := range path {
+		nLeaves, err := pr.varInt(fmt.Sprintf("leaf count of level %d", height))
+		if err != nil {
+			return pr.n, err
+		}
+
+		// The declared count is untrusted so is only used as a capacity hint, limited
+		// so that a bogus count can't force a large allocation.
+		level := make([]MerklePathLeaf, 0, minUint64(nLeaves, 1<<10))
+		offsets := make(map[uint64]bool, minUint64(nLeaves, 1<<10))
+		for i := uint64(0); i < nLeaves; i++ {
+			leaf, err := pr.leaf(height, i)
+			if err != nil {
+				return pr.n, err
+			}
+			if offsets[leaf.Offset] {
+				return pr.n, fmt.Errorf("%w: offset %d appears twice in level %d", ErrInvalidMerklePath, leaf.Offset, height)
+			}
+			offsets[leaf.Offset] = true
+			level = append(level, leaf)
+		}
+		path[height] = level
+	}
+
+	mp.BlockHeight = blockHeight
+	mp.Path = path
+
+	return pr.n, nil
+}
+
+// A pathReader reads the fields of a binary merkle path, counting the bytes read.
+type pathReader struct {
+	r io.Reader
+	n int64
+}
+
+// leaf reads leaf i of the level at height.
+func (pr *pathReader) leaf(height int, i uint64) (MerklePathLeaf, error) {
+	field := fmt.Sprintf("leaf %d of level %d", i, height)
+
+	offset, err := pr.varInt(field)
+	if err != nil {
+		return MerklePathLeaf{}, err
+	}
+	flags, err := pr.byte("flags of " + field)
+	if err != nil {
+		return MerklePathLeaf{}, err
+	}
+
+	leaf := MerklePathLeaf{Offset: offset}
+	switch flags {
+	case pathLeafDuplicate:
+		leaf.Duplicate = true
+		return leaf, nil
+	case pathLeafTxID:
+		leaf.TxID = true
+	case pathLeafHash:
+	default:
+		return MerklePathLeaf{}, fmt.Errorf("%w: flags %d of %s", ErrInvalidMerklePath, flags, field)
+	}
+
+	var hash Hash
+	if err := pr.full(hash[:], "hash of "+field); err != nil {
+		return MerklePathLeaf{}, err
+	}
+	leaf.Hash = &hash
+
+	return leaf, nil
+}
+
+// varInt reads a varint field.
+func (pr *pathReader) varInt(field string) (uint64, error) {
+	var v bt.VarInt
+	n, err := v.ReadFrom(pr.r)
+	pr.n += n
+	if err != nil {
+		return 0, pathReadErr(err, field)
+	}
+
+	return uint64(v), nil
+}
+
+// byte reads a single byte field.
+func (pr *pathReader) byte(field string) (byte, error) {
+	var b [1]byte
+	if err := pr.full(b[:], field); err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// full fills b from the reader.
+func (pr *pathReader) full(b []byte, field string) error {
+	n, err := io.ReadFull(pr.r, b)
+	pr.n += int64(n)
+	if err != nil {
+		return pathReadErr(err, field)
+	}
+
+	return nil
+}
+
+// pathReadErr wraps an error from reading a field of a merkle path, reporting
+// ErrMerklePathTruncated when it is because the reader ended.
+func pathReadErr(err error, field string) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: reading %s", ErrMerklePathTruncated, field)
+	}
+
+	return fmt.Errorf("reading %s: %w", field, err)
+}
+
+// flags returns the flags of the leaf in the binary encoding.
+func (l MerklePathLeaf) flags() (byte, error) {
+	switch {
+	case l.Duplicate && (l.Hash != nil || l.TxID):
+		return 0, fmt.Errorf("%w: duplicate leaf with a hash or txid", ErrInvalidMerklePath)
+	case l.Duplicate:
+		return pathLeafDuplicate, nil
+	case l.Hash == nil:
+		return 0, fmt.Errorf("%w: leaf without a hash", ErrInvalidMerklePath)
+	case l.TxID:
+		return pathLeafTxID, nil
+	default:
+		return pathLeafHash, nil
+	}
+}
+
+// TxIDs returns the txids of interest at the bottom of the path.
+func (mp *MerklePath) TxIDs() []Hash {
+	var txIDs []Hash
+	if len(mp.Path) == 0 {
+		return txIDs
+	}
+
+	for _, leaf := range mp.Path[0] {
+		if leaf.TxID && leaf.Hash != nil {
+			txIDs = append(txIDs, *leaf.Hash)
+		}
+	}
+
+	return txIDs
+}
+
+// MerkleRoot returns the merkle root the path leads to, calculated from the first
+// hash at the bottom of the path. Use CalculateRoot to check that a particular txid
+// leads to the root.
+func (mp *MerklePath) MerkleRoot() (Hash, error) {
+	if len(mp.Path) == 0 {
+		return Hash{}, fmt.Errorf("%w: no levels", ErrInvalidMerklePath)
+	}
+
+	for _, leaf := range mp.Path[0] {
+		if leaf.Hash != nil {
+			return mp.CalculateRoot(*leaf.Hash)
+		}
+	}
+
+	return Hash{}, fmt.Errorf("%w: no hashes in the bottom level", ErrInvalidMerklePath)
+}
+
+// CalculateRoot returns the merkle root the path leads to from txid, which must be one
+// of the hashes at the bottom of the path. Leaves which can be calculated from those
+// below them may be missing from the path.
+func (mp *MerklePath) CalculateRoot(txid Hash) (Hash, error) {
+	offset, err := mp.offset(txid)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	// a block of one transaction has a path of only its txid.
+	if len(mp.Path) == 1 && len(mp.Path[0]) == 1 {
+		if offset != 0 {
+			return Hash{}, fmt.Errorf("%w: offset %d of the only tx", ErrInvalidMerklePath, offset)
+		}
+		return txid, nil
+	}
+
+	working := txid
+	for height := range mp.Path {
+		pos := offset >> height
+		sibling, err := mp.leaf(height, pos^1)
+		if err != nil {
+			return Hash{}, err
+		}
+
+		switch {
+		case sibling.Duplicate && pos%2 == 1:
+			return Hash{}, fmt.Errorf("%w: duplicate on the left at level %d", ErrInvalidMerklePath, height)
+		case sibling.Duplicate:
+			working = TypedMerkleTreeParent(working, working)
+		case pos%2 == 1:
+			working = TypedMerkleTreeParent(*sibling.Hash, working)
+		default:
+			working = TypedMerkleTreeParent(working, *sibling.Hash)
+		}
+	}
+
+	return working, nil
+}
+
+// offset returns the offset of txid at the bottom of the path.
+func (mp *MerklePath) offset(txid Hash) (uint64, error) {
+	if len(mp.Path) == 0 || len(mp.Path) > maxPathHeight {
+		return 0, fmt.Errorf("%w: tree height %d", ErrInvalidMerklePath, len(mp.Path))
+	}
+
+	for _, leaf := range mp.Path[0] {
+		if leaf.Hash == nil || *leaf.Hash != txid {
+			continue
+		}
+		if len(mp.Path) < maxPathHeight && leaf.Offset>>len(mp.Path) != 0 {
+			return 0, fmt.Errorf("%w: offset %d beyond tree height %d", ErrInvalidMerklePath, leaf.Offset, len(mp.Path))
+		}
+		return leaf.Offset, nil
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrTxNotInMerklePath, txid)
+}
+
+// leaf returns the leaf at offset in the level at height, calculating it from the
+// levels below if it isn't held by the path.
+func (mp *MerklePath) leaf(height int, offset uint64) (*MerklePathLeaf, error) {
+	for i, leaf := range mp.Path[height] {
+		if leaf.Offset != offset {
+			continue
+		}
+		if !leaf.Duplicate && leaf.Hash == nil {
+			return nil, fmt.Errorf("%w: leaf without a hash at level %d offset %d", ErrInvalidMerklePath, height, offset)
+		}
+		return &mp.Path[height][i], nil
+	}
+
+	if height == 0 {
+		return nil, fmt.Errorf("%w: missing leaf at level 0 offset %d", ErrInvalidMerklePath, offset)
+	}
+
+	left, err := mp.leaf(height-1, offset*2)
+	if err != nil {
+		return nil, err
+	}
+	if left.Duplicate {
+		return nil, fmt.Errorf("%w: duplicate on the left at level %d", ErrInvalidMerklePath, height-1)
+	}
+	right, err := mp.leaf(height-1, offset*2+1)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := TypedMerkleTreeParent(*left.Hash, *left.Hash)
+	if !right.Duplicate {
+		hash = TypedMerkleTreeParent(*left.Hash, *right.Hash)
+	}
+
+	return &MerklePathLeaf{Offset: offset, Hash: &hash}, nil
+}
+
+// Combine merges the leaves of other, a path of the same block, into the path so that
+// it proves the txids of both. An error is returned if the paths are of different
+// blocks or hold different hashes at the same offset.
+func (mp *MerklePath) Combine(other *MerklePath) error {
+	if mp.BlockHeight != other.BlockHeight {
+		return fmt.Errorf("%w: block heights %d and %d differ", ErrInvalidMerklePath, mp.BlockHeight, other.BlockHeight)
+	}
+	if len(mp.Path) != len(other.Path) {
+		return fmt.Errorf("%w: tree heights %d and %d differ", ErrInvalidMerklePath, len(mp.Path), len(other.Path))
+	}
+
+	root, err := mp.MerkleRoot()
+	if err != nil {
+		return err
+	}
+	otherRoot, err := other.MerkleRoot()
+	if err != nil {
+		return err
+	}
+	if root != otherRoot {
+		return fmt.Errorf("%w: roots %s and %s differ", ErrInvalidMerklePath, root, otherRoot)
+	}
+
+	b := newPathBuilder()
+	for _, p := range []*MerklePath{mp, other} {
+		for height, level := range p.Path {
+			for _, leaf := range level {
+				b.add(height, leaf)
+			}
+		}
+	}
+	if b.err != nil {
+		return b.err
+	}
+
+	*mp = *b.path(mp.BlockHeight, len(mp.Path))
+
+	return nil
+}
+
+// MerkleProof returns a branch MerkleProof of txid, targeting the merkle root of the
+// path. The index of the proof is the offset of txid.
+//
+// The conversion is lossy: the proof covers txid alone, so the other txids of a path
+// holding several are dropped and need a proof each, and the block height of the path
+// is replaced by the merkle root.
+func (mp *MerklePath) MerkleProof(txid Hash) (*MerkleProof, error) {
+	root, err := mp.CalculateRoot(txid)
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := mp.offset(txid)
+
+	proof := &MerkleProof{
+		Index:      offset,
+		TxOrID:     txid.String(),
+		Target:     root.String(),
+		TargetType: "merkleRoot",
+		Nodes:      []string{},
+	}
+	if len(mp.Path) == 1 && len(mp.Path[0]) == 1 {
+		return proof, nil
+	}
+
+	for height := range mp.Path {
+		sibling, err := mp.leaf(height, (offset>>height)^1)
+		if err != nil {
+			return nil, err
+		}
+		if sibling.Duplicate {
+			proof.Nodes = append(proof.Nodes, "*")
+			continue
+		}
+		proof.Nodes = append(proof.Nodes, sibling.Hash.String())
+	}
+
+	return proof, nil
+}
+
+// A pathBuilder collects the leaves of a MerklePath, merging those at the same offset.
+type pathBuilder struct {
+	levels map[int]map[uint64]MerklePathLeaf
+	err    error
+}
+
+func newPathBuilder() *pathBuilder {
+	return &pathBuilder{levels: make(map[int]map[uint64]MerklePathLeaf)}
+}
+
+// add adds leaf to the level at height, recording an error if a different hash is
+// already held at its offset.
+func (b *pathBuilder) add(height int, leaf MerklePathLeaf) {
+	level, ok := b.levels[height]
+	if !ok {
+		level = make(map[uint64]MerklePathLeaf)
+		b.levels[height] = level
+	}
+
+	existing, ok := level[leaf.Offset]
+	if !ok {
+		level[leaf.Offset] = leaf
+		return
+	}
+
+	sameHash := existing.Hash == nil && leaf.Hash == nil ||
+		existing.Hash != nil && leaf.Hash != nil && *existing.Hash == *leaf.Hash
+	if existing.Duplicate != leaf.Duplicate || !sameHash {
+		if b.err == nil {
+			b.err = fmt.Errorf("%w: conflicting leaves at level %d offset %d", ErrInvalidMerklePath, height, leaf.Offset)
+		}
+		return
+	}
+	existing.TxID = existing.TxID || leaf.TxID
+	level[leaf.Offset] = existing
+}
+
+// path returns the MerklePath of the leaves added, sorted by offset within each level.
+func (b *pathBuilder) path(blockHeight uint64, treeHeight int) *MerklePath {
+	mp := &MerklePath{
+		BlockHeight: blockHeight,
+		Path:        make([][]MerklePathLeaf, treeHeight),
+	}
+	for height := range mp.Path {
+		level := make([]MerklePathLeaf, 0, len(b.levels[height]))
+		for _, leaf := range b.levels[height] {
+			level = append(level, leaf)
+		}
+		sort.Slice(level, func(i, j int) bool { return level[i].Offset < level[j].Offset })
+		mp.Path[height] = level
+	}
+
+	return mp
+}
@@ -0,0 +1,307 @@
+package bc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+// testMerklePath is the example of BRC-74, a path of two txids in block 813706.
+const (
+	testMerklePath     = "fe8a6a0c000c04fde80b0011774f01d26412f0d16ea3f0447be0b5ebec67b0782e321a7a01cbdf7f734e30fde90b02004e53753e3fe4667073063a17987292cfdea278824e9888e52180581d7188d8fdea0b025e441996fc53f0191d649e68a200e752fb5f39e0d5617083408fa179ddc5c998fdeb0b0102fdf405000671394f72237d08a4277f4435e5b6edf7adc272f25effef27cdfe805ce71a81fdf50500262bccabec6c4af3ed00cc7a7414edea9c5efa92fb8623dd6160a001450a528201fdfb020101fd7c010093b3efca9b77ddec914f8effac691ecb54e2c81d0ab81cbc4c4b93befe418e8501bf01015e005881826eb6973c54003a02118fe270f03d46d02681c8bc71cd44c613e86302f8012e00e07a2bb8bb75e5accff266022e1e5e6e7b4d6d943a04faadcf2ab4a22f796ff30116008120cafa17309c0bb0e0ffce835286b3a2dcae48e4497ae2d2b7ced4f051507d010a00502e59ac92f46543c23006bff855d96f5e648043f0fb87a7a5949e6a9bebae430104001ccd9f8f64f4d0489b30cc815351cf425e0e78ad79a589350e4341ac165dbe45010301010000af8764ce7e1cc132ab5ed2229a005c87201c9a5ee15c0f91dd53eff31ab30cd4"
+	testMerklePathRoot = "57aab6e6fb1b697174ffb64e062c4728f2ffd33ddcfa02a43b64d8cd29b483b4"
+)
+
+func TestMerklePath(t *testing.T) {
+	t.Parallel()
+
+	mp, err := bc.NewMerklePathFromStr(testMerklePath)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(813706), mp.BlockHeight)
+	assert.Len(t, mp.Path, 12)
+	assert.Equal(t, testMerklePath, mp.String())
+
+	txIDs := mp.TxIDs()
+	assert.Len(t, txIDs, 2)
+	assert.Equal(t, "d888711d588021e588984e8278a2decf927298173a06737066e43f3e75534e00", txIDs[0].String())
+	assert.Equal(t, "98c9c5dd79a18f40837061d5e0395ffb52e700a2689e641d19f053fc9619445e", txIDs[1].String())
+
+	for _, txID := range txIDs {
+		root, err := mp.CalculateRoot(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, testMerklePathRoot, root.String())
+	}
+	root, err := mp.MerkleRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, testMerklePathRoot, root.String())
+
+	_, err = mp.CalculateRoot(bc.Sha256dHash([]byte("not in the path")))
+	assert.True(t, errors.Is(err, bc.ErrTxNotInMerklePath))
+}
+
+func TestMerklePath_JSON(t *testing.T) {
+	t.Parallel()
+
+	mp, err := bc.NewMerklePathFromStr(testMerklePath)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(mp)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"offset":3049,"hash":"d888711d588021e588984e8278a2decf927298173a06737066e43f3e75534e00","txid":true}`)
+	assert.Contains(t, string(b), `{"offset":3051,"duplicate":true}`)
+
+	var decoded bc.MerklePath
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, mp, &decoded)
+}
+
+func TestMerklePath_MerkleProofRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	mp, err := bc.NewMerklePathFromStr(testMerklePath)
+	assert.NoError(t, err)
+
+	var combined *bc.MerklePath
+	for _, txID := range mp.TxIDs() {
+		proof, err := mp.MerkleProof(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, testMerklePathRoot, proof.Target)
+		root, _, err := proof.CalculateRoot()
+		assert.NoError(t, err)
+		assert.Equal(t, testMerklePathRoot, root.String())
+
+		single, err := bc.NewMerklePathFromMerkleProof(proof, mp.BlockHeight)
+		assert.NoError(t, err)
+		roundTrip, err := single.MerkleProof(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, proof, roundTrip)
+
+		if combined == nil {
+			combined = single
+			continue
+		}
+		assert.NoError(t, combined.Combine(single))
+	}
+
+	// the example is the combination of the paths of its two txids.
+	assert.Equal(t, testMerklePath, combined.String())
+}
+
+func TestMerklePath_MerkleProof_MultipleTxIDs(t *testing.T) {
+	t.Parallel()
+
+	mp, err := bc.NewMerklePathFromStr(testMerklePath)
+	assert.NoError(t, err)
+	txIDs := mp.TxIDs()
+	assert.Len(t, txIDs, 2)
+
+	proof, err := mp.MerkleProof(txIDs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, txIDs[0].String(), proof.TxOrID)
+	assert.False(t, proof.Composite)
+	assert.Empty(t, proof.Txs)
+
+	// only the txid of the proof survives converting it back.
+	single, err := bc.NewMerklePathFromMerkleProof(proof, mp.BlockHeight)
+	assert.NoError(t, err)
+	assert.Equal(t, []bc.Hash{txIDs[0]}, single.TxIDs())
+	assert.NotEqual(t, testMerklePath, single.String())
+	_, err = single.CalculateRoot(txIDs[1])
+	assert.True(t, errors.Is(err, bc.ErrTxNotInMerklePath))
+}
+
+func TestNewMerklePathFromMerkleProof(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 3, 11} {
+		_, txids := testTxIDs(n)
+		root, err := bc.BuildMerkleRoot(txids)
+		assert.NoError(t, err)
+
+		indices := make([]uint64, n)
+		for i := range indices {
+			indices[i] = uint64(i)
+		}
+		proofs, err := bc.MerkleProofsForIndices(txids, indices, "merkleRoot")
+		assert.NoError(t, err)
+
+		var combined *bc.MerklePath
+		for _, proof := range proofs {
+			mp, err := bc.NewMerklePathFromMerkleProof(proof, 100)
+			assert.NoError(t, err)
+			if combined == nil {
+				combined = mp
+				continue
+			}
+			assert.NoError(t, combined.Combine(mp))
+		}
+
+		assert.Len(t, combined.TxIDs(), n)
+		for _, txID := range combined.TxIDs() {
+			calculated, err := combined.CalculateRoot(txID)
+			assert.NoError(t, err)
+			assert.Equal(t, root, calculated.String(), "%d txids", n)
+		}
+
+		decoded, err := bc.NewMerklePathFromStr(combined.String())
+		assert.NoError(t, err)
+		assert.Equal(t, combined, decoded)
+	}
+}
+
+func TestNewMerklePathFromMerkleProof_Composite(t *testing.T) {
+	t.Parallel()
+
+	_, txids := testTxIDs(5)
+	merkles, err := bc.BuildMerkleTreeStore(txids)
+	assert.NoError(t, err)
+	h23, h0123, h4444, root := merkles[9], merkles[12], merkles[13], merkles[14]
+
+	tests := map[string]*bc.MerkleProof{
+		"composite branches": {
+			Index:     1,
+			TxOrID:    txids[1],
+			Composite: true,
+			Txs:       []bc.MerkleProofTx{{Index: 4, TxOrID: txids[4]}},
+			Nodes:     []string{txids[0], h23, h4444, "*", "*", h0123},
+		},
+		"composite tree": {
+			Index:     4,
+			TxOrID:    txids[4],
+			ProofType: "tree",
+			Composite: true,
+			Txs:       []bc.MerkleProofTx{{Index: 1, TxOrID: txids[1]}},
+			Nodes:     []string{txids[0], "*", h23, "*"},
+		},
+	}
+
+	for name, proof := range tests {
+		t.Run(name, func(t *testing.T) {
+			mp, err := bc.NewMerklePathFromMerkleProof(proof, 100)
+			assert.NoError(t, err)
+			assert.Len(t, mp.Path, 3)
+			assert.Len(t, mp.TxIDs(), 2)
+
+			for _, i := range []int{1, 4} {
+				txID, err := bc.NewHashFromString(txids[i])
+				assert.NoError(t, err)
+				calculated, err := mp.CalculateRoot(txID)
+				assert.NoError(t, err)
+				assert.Equal(t, root, calculated.String())
+			}
+		})
+	}
+}
+
+func TestMerklePath_Combine_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, txids := testTxIDs(3)
+	proofs, err := bc.MerkleProofsForIndices(txids, []uint64{0, 2}, "merkleRoot")
+	assert.NoError(t, err)
+	_, other := testTxIDs(4)
+	otherProofs, err := bc.MerkleProofsForIndices(other, []uint64{3}, "merkleRoot")
+	assert.NoError(t, err)
+
+	mp, err := bc.NewMerklePathFromMerkleProof(proofs[0], 100)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		proof  *bc.MerkleProof
+		height uint64
+	}{
+		"different block height": {
+			proof:  proofs[1],
+			height: 101,
+		},
+		"different root": {
+			proof:  otherProofs[0],
+			height: 100,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			other, err := bc.NewMerklePathFromMerkleProof(test.proof, test.height)
+			assert.NoError(t, err)
+			err = mp.Combine(other)
+			assert.True(t, errors.Is(err, bc.ErrInvalidMerklePath), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestMerklePath_UnmarshalBinary_Invalid(t *testing.T) {
+	t.Parallel()
+
+	mp, err := bc.NewMerklePathFromStr(testMerklePath)
+	assert.NoError(t, err)
+	b, err := mp.Bytes()
+	assert.NoError(t, err)
+
+	// every prefix of the path is truncated.
+	for i := 0; i < len(b); i++ {
+		_, err := bc.NewMerklePathFromBytes(b[:i])
+		assert.True(t, errors.Is(err, bc.ErrMerklePathTruncated), "prefix %d: %v", i, err)
+	}
+
+	txid := "11774f01d26412f0d16ea3f0447be0b5ebec67b0782e321a7a01cbdf7f734e30"
+	tests := map[string]string{
+		"zero tree height":    "01" + "00",
+		"unknown leaf flags":  "01" + "01" + "01" + "00" + "03" + txid,
+		"offset twice":        "01" + "01" + "02" + "00" + "00" + txid + "00" + "02" + txid,
+		"trailing bytes":      "01" + "01" + "01" + "00" + "02" + txid + "00",
+		"tree height over 64": "01" + "41",
+	}
+
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bc.NewMerklePathFromStr(s)
+			assert.True(t, errors.Is(err, bc.ErrInvalidMerklePath), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestMerklePath_ReadFrom(t *testing.T) {
+	t.Parallel()
+
+	b, err := hex.DecodeString(testMerklePath)
+	assert.NoError(t, err)
+
+	// a path stops after its last level, leaving whatever follows it in the reader.
+	r := bytes.NewReader(append(b, 0xbe, 0xef))
+	var mp bc.MerklePath
+	n, err := mp.ReadFrom(r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(b)), n)
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, testMerklePath, mp.String())
+
+	_, err = mp.ReadFrom(bytes.NewReader(b[:len(b)-1]))
+	assert.True(t, errors.Is(err, bc.ErrMerklePathTruncated))
+}
+
+func TestMerklePath_SingleTx(t *testing.T) {
+	t.Parallel()
+
+	txIDs, txids := testTxIDs(1)
+	proofs, err := bc.MerkleProofsForIndices(txids, []uint64{0}, "merkleRoot")
+	assert.NoError(t, err)
+
+	mp, err := bc.NewMerklePathFromMerkleProof(proofs[0], 1)
+	assert.NoError(t, err)
+	assert.Len(t, mp.Path, 1)
+
+	root, err := mp.CalculateRoot(txIDs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, txIDs[0], root)
+
+	proof, err := mp.MerkleProof(txIDs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, proofs[0], proof)
+}
@@ -1,7 +1,11 @@
 package bc
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
 
 	"github.com/libsv/go-bt/v2"
 
@@ -10,16 +14,53 @@ import (
 
 // A MerkleProof is a structure that proves inclusion of a
 // Bitcoin transaction in a block.
+//
+// A "branch" proof, the default ProofType, holds the nodes from the transaction up to
+// the merkle root. A "tree" proof holds the nodes needed to calculate the root from
+// all of the transactions it proves, level by level from the bottom of the tree and
+// left to right within a level, with a node omitted when it is calculated from the
+// transactions.
+//
+// A Composite proof proves the transactions of Txs as well as that of Index and
+// TxOrID, all in the same block. A composite branch proof holds the branch of each
+// transaction one after another, starting with the branch of Index and TxOrID.
 type MerkleProof struct {
-	Index      uint64   `json:"index"`
-	TxOrID     string   `json:"txOrId"`
-	Target     string   `json:"target"`
-	Nodes      []string `json:"nodes"`
-	TargetType string   `json:"targetType,omitempty"`
-	ProofType  string   `json:"proofType,omitempty"`
-	Composite  bool     `json:"composite,omitempty"`
+	Index      uint64          `json:"index"`
+	TxOrID     string          `json:"txOrId"`
+	Target     string          `json:"target"`
+	Nodes      []string        `json:"nodes"`
+	TargetType string          `json:"targetType,omitempty"`
+	ProofType  string          `json:"proofType,omitempty"`
+	Composite  bool            `json:"composite,omitempty"`
+	Txs        []MerkleProofTx `json:"txs,omitempty"`
+}
+
+// A MerkleProofTx is a further transaction proven by a composite MerkleProof.
+type MerkleProofTx struct {
+	Index  uint64 `json:"index"`
+	TxOrID string `json:"txOrId"`
 }
 
+const (
+	txOrIDFlag           byte = 1 << iota // 1 << 0 which is 00000001
+	targetTypeHeader                      // 1 << 1 which is 00000010
+	targetTypeMerkleRoot                  // 1 << 2 which is 00000100
+	proofTypeFlag                         // 1 << 3 which is 00001000
+	compositeFlag                         // 1 << 4 which is 00010000
+
+	targetTypeFlags  = targetTypeHeader | targetTypeMerkleRoot
+	merkleProofFlags = txOrIDFlag | targetTypeFlags | proofTypeFlag | compositeFlag
+)
+
+var (
+	// ErrMerkleProofTruncated is returned when a binary merkle proof ends before all
+	// of its fields have been read.
+	ErrMerkleProofTruncated = errors.New("merkle proof is truncated")
+	// ErrInvalidMerkleProofFlags is returned when a binary merkle proof sets flags which
+	// are undefined or can't be combined.
+	ErrInvalidMerkleProofFlags = errors.New("invalid merkle proof flags")
+)
+
 // Bytes converts the JSON Merkle Proof
 // into byte encoding.
 //
@@ -29,81 +70,637 @@ type MerkleProof struct {
 // index: 			varint,
 // txLength: 		varint, //omitted if flag bit 0 == 0 as it's a fixed length transaction ID
 // txOrId: 			byte[32 or variable length],
+// txCount: 		varint, //only if flag bit 4 == 1 as it's a composite proof
+// txs: 				{index, txLength, txOrId}[txCount],
 // target: 			byte[32 or 80], //determined by flag bits 1 and 2
 // nodeCount: 	varint,
 // nodes: 			node[]
+//
+// Hashes are written in wire order whereas a full transaction or header target is
+// written as is. The transactions of a composite proof must all be full transactions
+// or all be txids.
 func (mp MerkleProof) Bytes() ([]byte, error) {
-	index := bt.VarInt(mp.Index)
+	var flags byte
 
-	txOrID, err := hex.DecodeString(mp.TxOrID)
+	txOrID, isTx, err := decodeTxOrID(mp.TxOrID)
 	if err != nil {
 		return nil, err
 	}
-	txOrID = bt.ReverseBytes(txOrID)
+	if isTx {
+		flags |= txOrIDFlag
+	}
+
+	if len(mp.Txs) > 0 && !mp.Composite {
+		return nil, errors.New("txs are only allowed in a composite proof")
+	}
+	txs := make([][]byte, 0, len(mp.Txs))
+	for i, tx := range mp.Txs {
+		b, txIsTx, err := decodeTxOrID(tx.TxOrID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tx %d", i)
+		}
+		if txIsTx != isTx {
+			return nil, errors.Errorf("tx %d should be a %s like txOrId", i, map[bool]string{true: "tx", false: "txid"}[isTx])
+		}
+		txs = append(txs, b)
+	}
 
 	target, err := hex.DecodeString(mp.Target)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "invalid target")
+	}
+	targetLen := HashSize
+	switch mp.TargetType {
+	case "", "hash":
+		target = bt.ReverseBytes(target)
+	case "header":
+		flags |= targetTypeHeader
+		targetLen = blockHeaderLen
+	case "merkleRoot":
+		flags |= targetTypeMerkleRoot
+		target = bt.ReverseBytes(target)
+	default:
+		return nil, errors.Wrapf(ErrInvalidTargetType, "%q", mp.TargetType)
+	}
+	if len(target) != targetLen {
+		return nil, errors.Errorf("invalid target length %d for targetType %q", len(target), mp.TargetType)
 	}
-	target = bt.ReverseBytes(target)
 
-	nodeCount := len(mp.Nodes)
+	switch mp.ProofType {
+	case "", "branch":
+	case "tree":
+		flags |= proofTypeFlag
+	default:
+		return nil, errors.Errorf("invalid proofType %q", mp.ProofType)
+	}
 
-	nodes := []byte{}
+	if mp.Composite {
+		flags |= compositeFlag
+	}
+
+	b := []byte{flags}
+	b = appendProofTx(b, mp.Index, txOrID, isTx)
+	if mp.Composite {
+		b = append(b, bt.VarInt(uint64(len(txs))).Bytes()...)
+		for i, tx := range txs {
+			b = appendProofTx(b, mp.Txs[i].Index, tx, isTx)
+		}
+	}
+	b = append(b, target...)
+	b = append(b, bt.VarInt(uint64(len(mp.Nodes))).Bytes()...)
 
 	for _, n := range mp.Nodes {
 		if n == "*" {
-			nodes = append(nodes, []byte{1}...)
+			b = append(b, 1)
 			continue
 		}
 
-		nodes = append(nodes, []byte{0}...)
 		nb, err := hex.DecodeString(n)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrap(err, "invalid node")
 		}
-		nodes = append(nodes, bt.ReverseBytes(nb)...)
-
+		if len(nb) != HashSize {
+			return nil, errors.Errorf("invalid node length %d", len(nb))
+		}
+		b = append(b, 0)
+		b = append(b, bt.ReverseBytes(nb)...)
 	}
 
-	var flags uint8
-
-	var txLength []byte
-	if len(mp.TxOrID) > 64 { // tx bytes instead of txid
-		// set bit at index 0
-		flags |= 1 << 0
+	return b, nil
+}
 
-		txLength = bt.VarInt(uint64(len(txOrID))).Bytes()
+// decodeTxOrID decodes the hex txOrId of a proof, returning the bytes to write and
+// whether they are a full transaction rather than a txid.
+func decodeTxOrID(s string) ([]byte, bool, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "invalid txOrId")
 	}
-
-	if mp.TargetType == "header" {
-		// set bit at index 1
-		flags |= 1 << 1
-	} else if mp.TargetType == "merkleRoot" {
-		// set bit at index 2
-		flags |= 1 << 2
+	switch {
+	case len(b) > HashSize: // tx bytes instead of txid
+		return b, true, nil
+	case len(b) == HashSize:
+		return bt.ReverseBytes(b), false, nil
+	default:
+		return nil, false, errors.Errorf("invalid txOrId length %d", len(b))
 	}
+}
 
-	// ignore proofType and compositeType for this version
-
-	bytes := []byte{}
-	bytes = append(bytes, flags)
-	bytes = append(bytes, index.Bytes()...)
-	bytes = append(bytes, txLength...)
-	bytes = append(bytes, txOrID...)
-	bytes = append(bytes, target...)
-	bytes = append(bytes, byte(nodeCount))
-	bytes = append(bytes, nodes...)
+// appendProofTx appends the index, tx length when isTx, and txOrId of a proven
+// transaction to b.
+func appendProofTx(b []byte, index uint64, txOrID []byte, isTx bool) []byte {
+	b = append(b, bt.VarInt(index).Bytes()...)
+	if isTx {
+		b = append(b, bt.VarInt(uint64(len(txOrID))).Bytes()...)
+	}
 
-	return bytes, nil
+	return append(b, txOrID...)
 }
 
+// MarshalBinary implements the encoding.BinaryMarshaler interface, encoding the proof
+// as described by Bytes.
 func (mp MerkleProof) MarshalBinary() ([]byte, error) {
 	return mp.Bytes()
 }
 
 // UnmarshalBinary implements the encoding.BinaryUnmarshaler interface and reads a byte slice into
-// the object.
+// the object. The encoding is described by Bytes.
+//
+// ErrMerkleProofTruncated is returned if b ends early, ErrInvalidMerkleProofFlags if
+// its flags are invalid, and an error is returned if anything follows the last node.
 func (mp *MerkleProof) UnmarshalBinary(b []byte) error {
-	return errors.New("Not implemented")
+	r := bytes.NewReader(b)
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return errors.Wrap(ErrMerkleProofTruncated, "reading flags")
+	}
+	if flags&^merkleProofFlags != 0 || flags&targetTypeFlags == targetTypeFlags {
+		return errors.Wrapf(ErrInvalidMerkleProofFlags, "%08b", flags)
+	}
+
+	index, txOrID, err := readProofTx(r, flags, "txOrId")
+	if err != nil {
+		return err
+	}
+
+	var txs []MerkleProofTx
+	if flags&compositeFlag != 0 {
+		txCount, err := readProofVarInt(r, "tx count")
+		if err != nil {
+			return err
+		}
+		// every tx is more than 32 bytes so a count beyond the remaining bytes can be
+		// rejected before allocating for it.
+		if txCount > uint64(r.Len()) {
+			return errors.Wrapf(ErrMerkleProofTruncated, "%d txs in %d bytes", txCount, r.Len())
+		}
+
+		txs = make([]MerkleProofTx, 0, txCount)
+		for i := uint64(0); i < txCount; i++ {
+			idx, tx, err := readProofTx(r, flags, fmt.Sprintf("tx %d", i))
+			if err != nil {
+				return err
+			}
+			txs = append(txs, MerkleProofTx{Index: idx, TxOrID: tx})
+		}
+	}
+
+	targetLength := uint64(HashSize)
+	if flags&targetTypeHeader != 0 {
+		targetLength = blockHeaderLen
+	}
+	target, err := readProofBytes(r, targetLength, "target")
+	if err != nil {
+		return err
+	}
+
+	nodeCount, err := readProofVarInt(r, "node count")
+	if err != nil {
+		return err
+	}
+	// every node is at least one byte so a count beyond the remaining bytes can be
+	// rejected before allocating for it.
+	if nodeCount > uint64(r.Len()) {
+		return errors.Wrapf(ErrMerkleProofTruncated, "%d nodes in %d bytes", nodeCount, r.Len())
+	}
+
+	nodes := make([]string, 0, nodeCount)
+	for i := uint64(0); i < nodeCount; i++ {
+		t, err := r.ReadByte()
+		if err != nil {
+			return errors.Wrapf(ErrMerkleProofTruncated, "reading type of node %d", i)
+		}
+
+		switch t {
+		case 0:
+			n, err := readProofBytes(r, HashSize, fmt.Sprintf("node %d", i))
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, hex.EncodeToString(bt.ReverseBytes(n)))
+		case 1:
+			nodes = append(nodes, "*")
+		default:
+			return errors.Errorf("invalid type %d of node %d", t, i)
+		}
+	}
+
+	if r.Len() > 0 {
+		return errors.Errorf("%d unexpected bytes after last node", r.Len())
+	}
+
+	p := MerkleProof{
+		Index:  index,
+		TxOrID: txOrID,
+		Nodes:  nodes,
+		Txs:    txs,
+	}
+	switch {
+	case flags&targetTypeHeader != 0:
+		p.TargetType = "header"
+		p.Target = hex.EncodeToString(target)
+	case flags&targetTypeMerkleRoot != 0:
+		p.TargetType = "merkleRoot"
+		p.Target = hex.EncodeToString(bt.ReverseBytes(target))
+	default:
+		p.Target = hex.EncodeToString(bt.ReverseBytes(target))
+	}
+	if flags&proofTypeFlag != 0 {
+		p.ProofType = "tree"
+	}
+	p.Composite = flags&compositeFlag != 0
+
+	*mp = p
+
+	return nil
+}
+
+// readProofTx reads the index, tx length if set by flags, and txOrId of a proven
+// transaction, returning the txOrId as hex.
+func readProofTx(r *bytes.Reader, flags byte, field string) (uint64, string, error) {
+	index, err := readProofVarInt(r, field+" index")
+	if err != nil {
+		return 0, "", err
+	}
+
+	if flags&txOrIDFlag == 0 {
+		txid, err := readProofBytes(r, HashSize, field)
+		if err != nil {
+			return 0, "", err
+		}
+		return index, hex.EncodeToString(bt.ReverseBytes(txid)), nil
+	}
+
+	txLength, err := readProofVarInt(r, field+" length")
+	if err != nil {
+		return 0, "", err
+	}
+	if txLength <= HashSize {
+		return 0, "", errors.Errorf("invalid %s length %d, should be greater than %d bytes", field, txLength, HashSize)
+	}
+	tx, err := readProofBytes(r, txLength, field)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return index, hex.EncodeToString(tx), nil
+}
+
+// readProofVarInt reads the varint field of a binary merkle proof.
+func readProofVarInt(r *bytes.Reader, field string) (uint64, error) {
+	var v bt.VarInt
+	if _, err := v.ReadFrom(r); err != nil {
+		return 0, errors.Wrapf(ErrMerkleProofTruncated, "reading %s", field)
+	}
+
+	return uint64(v), nil
+}
+
+// readProofBytes reads the n byte field of a binary merkle proof.
+func readProofBytes(r *bytes.Reader, n uint64, field string) ([]byte, error) {
+	if n > uint64(r.Len()) {
+		return nil, errors.Wrapf(ErrMerkleProofTruncated, "reading %d bytes of %s, %d remain", n, field, r.Len())
+	}
+
+	b := make([]byte, n)
+	_, _ = r.Read(b)
+
+	return b, nil
+}
+
+// A TypedMerkleProof is a MerkleProof of a txid whose hashes are held as Hash. It
+// marshals to and from the same JSON as a MerkleProof.
+type TypedMerkleProof struct {
+	Index uint64
+	TxID  Hash
+	// Target is the block hash, or the merkle root when TargetType is "merkleRoot",
+	// of the block the transaction is in.
+	Target     Hash
+	TargetType string
+	// Nodes are the merkle tree nodes from the txid up to the root, a nil node
+	// meaning the duplicate of the hash calculated so far as found at the end of
+	// a layer with an odd number of nodes.
+	Nodes []*Hash
+}
+
+// Typed returns the proof as a TypedMerkleProof. When the proof contains a full
+// transaction it is replaced by its txid, and when it targets a header the target
+// is replaced by the block hash of the header.
+//
+// Only single branch proofs can be held by a TypedMerkleProof, so an error is
+// returned for tree and composite proofs.
+func (mp MerkleProof) Typed() (*TypedMerkleProof, error) {
+	if mp.Composite || (mp.ProofType != "" && mp.ProofType != "branch") {
+		return nil, errors.New("only single branch proofs can be typed")
+	}
+
+	tmp := &TypedMerkleProof{
+		Index: mp.Index,
+		Nodes: make([]*Hash, 0, len(mp.Nodes)),
+	}
+
+	txID, err := proofTxID(mp.TxOrID)
+	if err != nil {
+		return nil, err
+	}
+	tmp.TxID = txID
+
+	switch mp.TargetType {
+	case "", "hash":
+		tmp.Target, err = NewHashFromString(mp.Target)
+	case "header":
+		var bh *BlockHeader
+		if bh, err = NewBlockHeaderFromStr(mp.Target); err == nil {
+			tmp.Target, err = bh.Hash()
+		}
+	case "merkleRoot":
+		tmp.TargetType = mp.TargetType
+		tmp.Target, err = NewHashFromString(mp.Target)
+	default:
+		return nil, errors.Errorf("invalid targetType %q", mp.TargetType)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid target")
+	}
+
+	if tmp.Nodes, err = proofNodes(mp.Nodes); err != nil {
+		return nil, err
+	}
+
+	return tmp, nil
+}
+
+// proofTxID returns the txid of the txOrId of a proof.
+func proofTxID(txOrID string) (Hash, error) {
+	if len(txOrID) > HashSize*2 {
+		tx, err := bt.NewTxFromString(txOrID)
+		if err != nil {
+			return Hash{}, errors.Wrap(err, "invalid txOrId")
+		}
+		txOrID = tx.TxID()
+	}
+
+	txID, err := NewHashFromString(txOrID)
+	if err != nil {
+		return Hash{}, errors.Wrap(err, "invalid txOrId")
+	}
+
+	return txID, nil
+}
+
+// proofNodes returns the nodes of a proof as hashes, with nil in place of "*".
+func proofNodes(nodes []string) ([]*Hash, error) {
+	hashes := make([]*Hash, 0, len(nodes))
+	for _, n := range nodes {
+		if n == "*" {
+			hashes = append(hashes, nil)
+			continue
+		}
+
+		node, err := NewHashFromString(n)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid node")
+		}
+		hashes = append(hashes, &node)
+	}
+
+	return hashes, nil
+}
+
+// CalculateRoot returns the merkle root the proof leads to from its transactions,
+// along with whether the transaction of Index and TxOrID is the last in its block.
+//
+// Branch, tree and composite proofs are supported. An error is returned if the
+// nodes don't fit the transactions, or if the branches of a composite proof lead
+// to different roots.
+func (mp MerkleProof) CalculateRoot() (Hash, bool, error) {
+	if len(mp.Txs) > 0 && !mp.Composite {
+		return Hash{}, false, errors.New("txs are only allowed in a composite proof")
+	}
+
+	leaves, err := mp.leaves()
+	if err != nil {
+		return Hash{}, false, err
+	}
+
+	nodes, err := proofNodes(mp.Nodes)
+	if err != nil {
+		return Hash{}, false, err
+	}
+
+	switch mp.ProofType {
+	case "", "branch":
+		return branchesRoot(leaves, nodes)
+	case "tree":
+		root, last, _, err := treeRoot(leaves, nodes, nil)
+		return root, last, err
+	default:
+		return Hash{}, false, errors.Errorf("invalid proofType %q", mp.ProofType)
+	}
+}
+
+// leaves returns the txids proven by the proof at their positions at the bottom of the
+// merkle tree, starting with that of Index and TxOrID.
+func (mp MerkleProof) leaves() ([]proofNode, error) {
+	leaves := make([]proofNode, 0, len(mp.Txs)+1)
+	txID, err := proofTxID(mp.TxOrID)
+	if err != nil {
+		return nil, err
+	}
+	leaves = append(leaves, proofNode{pos: mp.Index, hash: txID})
+	for i, tx := range mp.Txs {
+		txID, err := proofTxID(tx.TxOrID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tx %d", i)
+		}
+		leaves = append(leaves, proofNode{pos: tx.Index, hash: txID})
+	}
+
+	seen := make(map[uint64]bool, len(leaves))
+	for _, l := range leaves {
+		if seen[l.pos] {
+			return nil, errors.Errorf("index %d is proven more than once", l.pos)
+		}
+		seen[l.pos] = true
+	}
+
+	return leaves, nil
+}
+
+// A proofNode is a hash of a merkle tree along with its position in its level.
+type proofNode struct {
+	pos  uint64
+	hash Hash
+}
+
+// branchesRoot returns the root of the branches of a single or composite branch
+// proof, each of the leaves having an equal share of nodes, and whether the first
+// leaf is the last in its tree.
+func branchesRoot(leaves []proofNode, nodes []*Hash) (Hash, bool, error) {
+	if len(nodes)%len(leaves) != 0 {
+		return Hash{}, false, errors.Errorf("%d nodes can't be shared between %d branches", len(nodes), len(leaves))
+	}
+	depth := len(nodes) / len(leaves)
+
+	var root Hash
+	var last bool
+	for i, l := range leaves {
+		tmp := &TypedMerkleProof{Index: l.pos, TxID: l.hash, Nodes: nodes[i*depth : (i+1)*depth]}
+		r, isLast, err := tmp.root()
+		if err != nil {
+			return Hash{}, false, errors.Wrapf(err, "branch %d", i)
+		}
+		if i == 0 {
+			root, last = r, isLast
+			continue
+		}
+		if r != root {
+			return Hash{}, false, errors.Errorf("branch %d leads to root %s rather than %s", i, r, root)
+		}
+	}
+
+	return root, last, nil
+}
+
+// treeRoot returns the root of a tree proof of leaves, whether the first leaf is the
+// last in its tree, and the height of the tree. Working up from the leaves a level at
+// a time, a node is taken for each hash whose sibling isn't known, until the root is
+// the only hash left and every node has been used.
+//
+// If visit isn't nil it is called with each node taken along with its height and
+// position in the tree.
+func treeRoot(leaves []proofNode, nodes []*Hash, visit func(height int, pos uint64, node *Hash)) (Hash, bool, int, error) {
+	level := append([]proofNode{}, leaves...)
+	sort.Slice(level, func(i, j int) bool { return level[i].pos < level[j].pos })
+
+	primary := leaves[0].pos
+	last := true
+	for height := 0; ; height++ {
+		if len(nodes) == 0 && len(level) == 1 && level[0].pos == 0 {
+			return level[0].hash, last, height, nil
+		}
+
+		parents := make([]proofNode, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i++ {
+			n := level[i]
+
+			var l, r Hash
+			switch {
+			case n.pos%2 == 0 && i+1 < len(level) && level[i+1].pos == n.pos+1:
+				l, r = n.hash, level[i+1].hash
+				i++
+			case len(nodes) == 0:
+				return Hash{}, false, 0, errors.New("too few nodes for the txs of the proof")
+			default:
+				node := nodes[0]
+				nodes = nodes[1:]
+				if visit != nil {
+					visit(height, n.pos^1, node)
+				}
+
+				switch {
+				case n.pos%2 == 1 && node == nil:
+					return Hash{}, false, 0, errors.New("duplicate node on the left of the merkle tree")
+				case n.pos%2 == 1:
+					l, r = *node, n.hash
+				case node == nil:
+					l, r = n.hash, n.hash
+				default:
+					l, r = n.hash, *node
+				}
+			}
+
+			if n.pos == primary && n.pos%2 == 0 && l != r {
+				last = false
+			}
+			parents = append(parents, proofNode{pos: n.pos / 2, hash: TypedMerkleTreeParent(l, r)})
+		}
+
+		primary /= 2
+		level = parents
+	}
+}
+
+// MerkleProof returns the proof as a MerkleProof.
+func (mp *TypedMerkleProof) MerkleProof() *MerkleProof {
+	nodes := make([]string, 0, len(mp.Nodes))
+	for _, n := range mp.Nodes {
+		if n == nil {
+			nodes = append(nodes, "*")
+			continue
+		}
+		nodes = append(nodes, n.String())
+	}
+
+	return &MerkleProof{
+		Index:      mp.Index,
+		TxOrID:     mp.TxID.String(),
+		Target:     mp.Target.String(),
+		TargetType: mp.TargetType,
+		Nodes:      nodes,
+	}
+}
+
+// MarshalJSON marshals the proof into the same JSON as a MerkleProof, with "*" in
+// place of nil nodes.
+func (mp *TypedMerkleProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mp.MerkleProof())
+}
+
+// UnmarshalJSON unmarshals the JSON of a single branch MerkleProof into the receiving
+// proof.
+func (mp *TypedMerkleProof) UnmarshalJSON(b []byte) error {
+	var p MerkleProof
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	typed, err := p.Typed()
+	if err != nil {
+		return err
+	}
+	*mp = *typed
+
+	return nil
+}
+
+// MerkleRoot calculates the merkle root the proof leads to from its txid.
+func (mp *TypedMerkleProof) MerkleRoot() (Hash, error) {
+	root, _, err := mp.root()
+	return root, err
+}
+
+// root returns the merkle root the proof leads to along with whether its transaction
+// is the last in its block, which is when the nodes to the right of it are duplicates.
+func (mp *TypedMerkleProof) root() (Hash, bool, error) {
+	hash := mp.TxID
+	index := mp.Index
+	last := true
+	for _, n := range mp.Nodes {
+		isLeft := index%2 == 0
+		node := hash
+		if n != nil {
+			node = *n
+		} else if !isLeft {
+			return Hash{}, false, errors.New("duplicate node on the left of the merkle tree")
+		}
+
+		if isLeft && node != hash {
+			last = false
+		}
+
+		if isLeft {
+			hash = TypedMerkleTreeParent(hash, node)
+		} else {
+			hash = TypedMerkleTreeParent(node, hash)
+		}
+		index /= 2
+	}
+
+	if index > 0 {
+		return Hash{}, false, errors.Errorf("index %d out of range for proof of length %d", mp.Index, len(mp.Nodes))
+	}
+
+	return hash, last, nil
 }
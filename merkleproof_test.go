@@ -2,6 +2,8 @@ package bc_test
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -58,3 +60,422 @@ func TestMerkleProofBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestTypedMerkleProof(t *testing.T) {
+	t.Parallel()
+
+	txids := []string{
+		"b9ef07a62553ef8b0898a79c291b92c60f7932260888bde0dab2dd2610d8668e",
+		"0fc1c12fb1b57b38140442927fbadb3d1e5a5039a5d6db355ea25486374f104d",
+		"60b0e75dd5b8d48f2d069229f20399e07766dd651ceeed55ee3c040aa2812547",
+	}
+	root, err := bc.BuildMerkleRoot(txids)
+	assert.NoError(t, err)
+	left, err := bc.MerkleTreeParentStr(txids[0], txids[1])
+	assert.NoError(t, err)
+	right, err := bc.MerkleTreeParentStr(txids[2], txids[2])
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		proof   *bc.MerkleProof
+		expRoot string
+		expErr  bool
+	}{
+		"left node": {
+			proof: &bc.MerkleProof{
+				Index:      0,
+				TxOrID:     txids[0],
+				Target:     root,
+				TargetType: "merkleRoot",
+				Nodes:      []string{txids[1], right},
+			},
+			expRoot: root,
+		},
+		"duplicated last node": {
+			proof: &bc.MerkleProof{
+				Index:      2,
+				TxOrID:     txids[2],
+				Target:     root,
+				TargetType: "merkleRoot",
+				Nodes:      []string{"*", left},
+			},
+			expRoot: root,
+		},
+		"duplicate node on the left": {
+			proof: &bc.MerkleProof{
+				Index:      1,
+				TxOrID:     txids[1],
+				Target:     root,
+				TargetType: "merkleRoot",
+				Nodes:      []string{"*", left},
+			},
+			expErr: true,
+		},
+		"index out of range": {
+			proof: &bc.MerkleProof{
+				Index:      4,
+				TxOrID:     txids[2],
+				Target:     root,
+				TargetType: "merkleRoot",
+				Nodes:      []string{"*", left},
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmp, err := test.proof.Typed()
+			assert.NoError(t, err)
+			assert.Equal(t, test.proof, tmp.MerkleProof())
+
+			js, err := json.Marshal(tmp)
+			assert.NoError(t, err)
+			var unmarshalled bc.TypedMerkleProof
+			assert.NoError(t, json.Unmarshal(js, &unmarshalled))
+			assert.Equal(t, tmp, &unmarshalled)
+			var proof bc.MerkleProof
+			assert.NoError(t, json.Unmarshal(js, &proof))
+			assert.Equal(t, test.proof, &proof)
+
+			calculated, err := tmp.MerkleRoot()
+			if test.expErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expRoot, calculated.String())
+			assert.Equal(t, tmp.Target, calculated)
+		})
+	}
+}
+
+func TestMerkleProof_UnmarshalBinary_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	b, err := bc.NewBlockFromStr(testBlockStr)
+	assert.NoError(t, err)
+	txid := b.Txs[1].TxID()
+	hash, err := b.BlockHeader.Hash()
+	assert.NoError(t, err)
+
+	manyNodes := make([]string, 300)
+	for i := range manyNodes {
+		manyNodes[i] = txid
+	}
+
+	tests := map[string]*bc.MerkleProof{
+		"txid and block hash": {
+			Index:  5,
+			TxOrID: txid,
+			Target: hash.String(),
+			Nodes:  []string{txid, "*"},
+		},
+		"tx and header": {
+			Index:      2,
+			TxOrID:     b.Txs[1].String(),
+			Target:     b.BlockHeader.String(),
+			TargetType: "header",
+			Nodes:      []string{"*", txid},
+		},
+		"merkle root tree composite": {
+			Index:      300,
+			TxOrID:     txid,
+			Target:     hash.String(),
+			TargetType: "merkleRoot",
+			ProofType:  "tree",
+			Composite:  true,
+			Txs:        []bc.MerkleProofTx{{Index: 2, TxOrID: txid}, {Index: 7, TxOrID: txid}},
+			Nodes:      []string{txid},
+		},
+		"composite txs": {
+			Index:      2,
+			TxOrID:     b.Txs[1].String(),
+			Target:     b.BlockHeader.String(),
+			TargetType: "header",
+			Composite:  true,
+			Txs:        []bc.MerkleProofTx{{Index: 1, TxOrID: b.Txs[2].String()}},
+			Nodes:      []string{"*", txid},
+		},
+		"no nodes": {
+			TxOrID: txid,
+			Target: hash.String(),
+			Nodes:  []string{},
+		},
+		"varint node count": {
+			Index:  1 << 40,
+			TxOrID: txid,
+			Target: hash.String(),
+			Nodes:  manyNodes,
+		},
+	}
+
+	for name, proof := range tests {
+		t.Run(name, func(t *testing.T) {
+			bb, err := proof.MarshalBinary()
+			assert.NoError(t, err)
+
+			var decoded bc.MerkleProof
+			assert.NoError(t, decoded.UnmarshalBinary(bb))
+			assert.Equal(t, proof, &decoded)
+
+			// every prefix of the proof is truncated.
+			for i := 0; i < len(bb); i++ {
+				err := decoded.UnmarshalBinary(bb[:i])
+				assert.True(t, errors.Is(err, bc.ErrMerkleProofTruncated), "prefix %d: %v", i, err)
+			}
+		})
+	}
+}
+
+func TestMerkleProof_UnmarshalBinary_Vectors(t *testing.T) {
+	t.Parallel()
+
+	bb, err := hex.DecodeString("0005d9615c2ac0861d4b6250845d30de93b7b67eae8269bd3e17f3e4d04de9b9484846cde6396797e09fced3c084cd1638e72f93be0678a1b0f4c8c38665be2eea6203006143900ad9eb3baef0d7929f2a0e07cf0a7912ad86380283d4ab81aa3002aae300c02fd1e9c867713fd41bd219675c2a4e69af620bb0c1b7a4ef01878d550963f400562a18bfc3d35116bcf475ab8d482178b0811b34e4c269c33ad347ad0bc8e539")
+	assert.NoError(t, err)
+
+	var mp bc.MerkleProof
+	assert.NoError(t, mp.UnmarshalBinary(bb))
+	assert.Equal(t, bc.MerkleProof{
+		Index:  5,
+		TxOrID: "4848b9e94dd0e4f3173ebd6982ae7eb6b793de305d8450624b1d86c02a5c61d9",
+		Target: "62ea2ebe6586c3c8f4b0a17806be932fe73816cd84c0d3ce9fe0976739e6cd46",
+		Nodes: []string{
+			"e3aa0230aa81abd483023886ad12790acf070e2a9f92d7f0ae3bebd90a904361",
+			"f46309558d8701efa4b7c1b00b62af694e2a5c6719d21bd43f7167c8e9d12fc0",
+			"39e5c80bad47d33ac369c2e4341b81b07821488dab75f4bc1651d3c3bf182a56",
+		},
+	}, mp)
+}
+
+func TestMerkleProof_UnmarshalBinary_Invalid(t *testing.T) {
+	t.Parallel()
+
+	txid := "4848b9e94dd0e4f3173ebd6982ae7eb6b793de305d8450624b1d86c02a5c61d9"
+	valid := "00" + "05" + txid + txid + "01" + "01"
+
+	tests := map[string]struct {
+		proof  string
+		expErr error
+	}{
+		"empty": {
+			proof:  "",
+			expErr: bc.ErrMerkleProofTruncated,
+		},
+		"undefined flag": {
+			proof:  "20" + valid[2:],
+			expErr: bc.ErrInvalidMerkleProofFlags,
+		},
+		"header and merkle root target": {
+			proof:  "06" + valid[2:],
+			expErr: bc.ErrInvalidMerkleProofFlags,
+		},
+		"node count beyond proof": {
+			proof:  "00" + "05" + txid + txid + "fdffff" + "01",
+			expErr: bc.ErrMerkleProofTruncated,
+		},
+		"invalid node type": {
+			proof: "00" + "05" + txid + txid + "01" + "03",
+		},
+		"trailing bytes": {
+			proof: valid + "00",
+		},
+		"short tx": {
+			proof: "01" + "05" + "20" + txid + txid + "01" + "01",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bb, err := hex.DecodeString(test.proof)
+			assert.NoError(t, err)
+
+			var mp bc.MerkleProof
+			err = mp.UnmarshalBinary(bb)
+			assert.Error(t, err)
+			if test.expErr != nil {
+				assert.True(t, errors.Is(err, test.expErr), "unexpected error %v", err)
+			}
+		})
+	}
+
+	var mp bc.MerkleProof
+	bb, err := hex.DecodeString(valid)
+	assert.NoError(t, err)
+	assert.NoError(t, mp.UnmarshalBinary(bb))
+}
+
+func TestMerkleProof_Bytes_Invalid(t *testing.T) {
+	t.Parallel()
+
+	txid := "4848b9e94dd0e4f3173ebd6982ae7eb6b793de305d8450624b1d86c02a5c61d9"
+
+	tests := map[string]bc.MerkleProof{
+		"short txid": {
+			TxOrID: txid[:62],
+			Target: txid,
+		},
+		"header target of a hash": {
+			TxOrID:     txid,
+			Target:     txid,
+			TargetType: "header",
+		},
+		"unknown target type": {
+			TxOrID:     txid,
+			Target:     txid,
+			TargetType: "block",
+		},
+		"unknown proof type": {
+			TxOrID:    txid,
+			Target:    txid,
+			ProofType: "path",
+		},
+		"short node": {
+			TxOrID: txid,
+			Target: txid,
+			Nodes:  []string{txid[:62]},
+		},
+	}
+
+	for name, proof := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := proof.Bytes()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMerkleProof_CalculateRoot(t *testing.T) {
+	t.Parallel()
+
+	txids := []string{
+		"b6d4d13aa08bb4b6cdb3b329cef29b5a5d55d85a85c330d56fddbce78d99c7d6",
+		"426f65f6a6ce79c909e54d8959c874a767db3076e76031be70942b896cc64052",
+		"adc23d36cc457d5847968c2e4d5f017a6f12a2f165102d10d2843f5276cfe68e",
+		"728714bbbddd81a54cae473835ae99eb92ed78191327eb11a9d7494273dcad2a",
+		"e3aa0230aa81abd483023886ad12790acf070e2a9f92d7f0ae3bebd90a904361",
+	}
+	// the tree is stored as [t0 t1 t2 t3 t4 - - - h01 h23 h44 - h0123 h4444 root].
+	merkles, err := bc.BuildMerkleTreeStore(txids)
+	assert.NoError(t, err)
+	h23, h0123, h4444, root := merkles[9], merkles[12], merkles[13], merkles[14]
+
+	tests := map[string]struct {
+		proof   bc.MerkleProof
+		expLast bool
+		expErr  bool
+	}{
+		"branch": {
+			proof: bc.MerkleProof{Index: 1, TxOrID: txids[1], Nodes: []string{txids[0], h23, h4444}},
+		},
+		"last in tree branch": {
+			proof:   bc.MerkleProof{Index: 4, TxOrID: txids[4], Nodes: []string{"*", "*", h0123}},
+			expLast: true,
+		},
+		"composite branches": {
+			proof: bc.MerkleProof{
+				Index:     1,
+				TxOrID:    txids[1],
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 4, TxOrID: txids[4]}},
+				Nodes:     []string{txids[0], h23, h4444, "*", "*", h0123},
+			},
+		},
+		"composite branches to different roots": {
+			proof: bc.MerkleProof{
+				Index:     1,
+				TxOrID:    txids[1],
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 4, TxOrID: txids[4]}},
+				Nodes:     []string{txids[0], h23, h4444, "*", "*", h23},
+			},
+			expErr: true,
+		},
+		"composite branches of uneven length": {
+			proof: bc.MerkleProof{
+				Index:     1,
+				TxOrID:    txids[1],
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 4, TxOrID: txids[4]}},
+				Nodes:     []string{txids[0], h23, h4444, "*", "*"},
+			},
+			expErr: true,
+		},
+		"tree of one tx": {
+			proof: bc.MerkleProof{Index: 1, TxOrID: txids[1], ProofType: "tree", Nodes: []string{txids[0], h23, h4444}},
+		},
+		"composite tree": {
+			proof: bc.MerkleProof{
+				Index:     4,
+				TxOrID:    txids[4],
+				ProofType: "tree",
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 1, TxOrID: txids[1]}},
+				Nodes:     []string{txids[0], "*", h23, "*"},
+			},
+			expLast: true,
+		},
+		"composite tree of siblings": {
+			proof: bc.MerkleProof{
+				Index:     3,
+				TxOrID:    txids[3],
+				ProofType: "tree",
+				Composite: true,
+				Txs: []bc.MerkleProofTx{
+					{Index: 0, TxOrID: txids[0]},
+					{Index: 1, TxOrID: txids[1]},
+					{Index: 2, TxOrID: txids[2]},
+				},
+				Nodes: []string{h4444},
+			},
+		},
+		"tree with too few nodes": {
+			proof: bc.MerkleProof{
+				Index:     4,
+				TxOrID:    txids[4],
+				ProofType: "tree",
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 1, TxOrID: txids[1]}},
+				Nodes:     []string{txids[0], "*", h23},
+			},
+			expErr: true,
+		},
+		"tree with duplicate on the left": {
+			proof:  bc.MerkleProof{Index: 1, TxOrID: txids[1], ProofType: "tree", Nodes: []string{"*", h23, h4444}},
+			expErr: true,
+		},
+		"tx proven twice": {
+			proof: bc.MerkleProof{
+				Index:     1,
+				TxOrID:    txids[1],
+				ProofType: "tree",
+				Composite: true,
+				Txs:       []bc.MerkleProofTx{{Index: 1, TxOrID: txids[1]}},
+				Nodes:     []string{txids[0], h23, h4444},
+			},
+			expErr: true,
+		},
+		"txs of a single proof": {
+			proof: bc.MerkleProof{
+				Index:  1,
+				TxOrID: txids[1],
+				Txs:    []bc.MerkleProofTx{{Index: 4, TxOrID: txids[4]}},
+				Nodes:  []string{txids[0], h23, h4444, "*", "*", h0123},
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calculated, last, err := test.proof.CalculateRoot()
+			if test.expErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, root, calculated.String())
+			assert.Equal(t, test.expLast, last)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package bc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTxIndexOutOfRange is returned when a merkle proof is requested for an index
+	// beyond the transactions of a block.
+	ErrTxIndexOutOfRange = errors.New("tx index out of range")
+	// ErrInvalidTargetType is returned when a merkle proof is requested with a target
+	// type other than "", "hash", "header" or "merkleRoot".
+	ErrInvalidTargetType = errors.New("invalid target type")
+	// ErrTargetRequiresHeader is returned when merkle proofs targeting a block hash or
+	// header are requested from txids alone, which don't determine the header.
+	ErrTargetRequiresHeader = errors.New("target type requires the block header")
+)
+
+// MerkleProofsForIndices returns a MerkleProof for each of the txids at indices,
+// building the merkle tree of txids once and reading every proof from it. The txids
+// are hex strings in display order, as given to BuildMerkleRoot.
+//
+// As txids alone don't determine the block header, the only targetType supported is
+// "merkleRoot", with ErrTargetRequiresHeader returned otherwise. Use Block.MerkleProofs
+// for proofs targeting the block hash or header.
+func MerkleProofsForIndices(txids []string, indices []uint64, targetType string) ([]*MerkleProof, error) {
+	switch targetType {
+	case "merkleRoot":
+	case "", "hash", "header":
+		return nil, fmt.Errorf("%w: %q", ErrTargetRequiresHeader, targetType)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTargetType, targetType)
+	}
+
+	hashes := make([]Hash, len(txids))
+	for i, txid := range txids {
+		h, err := NewHashFromString(txid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid txid at index %d: %w", i, err)
+		}
+		hashes[i] = h
+	}
+
+	proofs, root, err := merkleProofs(hashes, indices)
+	if err != nil {
+		return nil, err
+	}
+	for _, mp := range proofs {
+		mp.Target = root.String()
+		mp.TargetType = targetType
+	}
+
+	return proofs, nil
+}
+
+// MerkleProofs returns a MerkleProof for each of the transactions of the block at
+// indices, building the merkle tree of the block once and reading every proof from it.
+//
+// targetType is that of the TSC merkle proof format: "" or "hash" to target the block
+// hash, "header" to target the block header and "merkleRoot" to target the merkle root.
+func (b *Block) MerkleProofs(indices []uint64, targetType string) ([]*MerkleProof, error) {
+	var target string
+	switch targetType {
+	case "", "hash":
+		hash, err := b.BlockHeader.Hash()
+		if err != nil {
+			return nil, err
+		}
+		target = hash.String()
+	case "header":
+		header, err := b.BlockHeader.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		target = hex.EncodeToString(header)
+	case "merkleRoot":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTargetType, targetType)
+	}
+
+	proofs, root, err := merkleProofs(b.TxIDs(), indices)
+	if err != nil {
+		return nil, err
+	}
+	if targetType == "merkleRoot" {
+		target = root.String()
+	}
+	for _, mp := range proofs {
+		mp.Target = target
+		mp.TargetType = targetType
+	}
+
+	return proofs, nil
+}
+
+// merkleProofs returns the proofs, without their target, of the txids at indices
+// along with the merkle root of txids.
+func merkleProofs(txids []Hash, indices []uint64) ([]*MerkleProof, Hash, error) {
+	for _, idx := range indices {
+		if idx >= uint64(len(txids)) {
+			return nil, Hash{}, fmt.Errorf("%w: index %d of %d txs", ErrTxIndexOutOfRange, idx, len(txids))
+		}
+	}
+	if len(txids) == 0 {
+		return []*MerkleProof{}, Hash{}, nil
+	}
+
+	merkles := BuildTypedMerkleTreeStore(txids)
+	proofs := make([]*MerkleProof, 0, len(indices))
+	for _, idx := range indices {
+		mp := &MerkleProof{
+			Index:  idx,
+			TxOrID: txids[idx].String(),
+			Nodes:  []string{},
+		}
+
+		// walk up the levels of the linear array as laid out by BuildTypedMerkleTreeStore,
+		// where n is the number of nodes in the level which aren't empty.
+		pos := idx
+		for offset, width, n := uint64(0), uint64(len(merkles)+1)/2, uint64(len(txids)); width > 1; offset, width, n = offset+width, width/2, (n+1)/2 {
+			sibling := pos ^ 1
+			if sibling >= n {
+				mp.Nodes = append(mp.Nodes, "*")
+			} else {
+				mp.Nodes = append(mp.Nodes, merkles[offset+sibling].String())
+			}
+			pos /= 2
+		}
+
+		proofs = append(proofs, mp)
+	}
+
+	return proofs, merkles[len(merkles)-1], nil
+}
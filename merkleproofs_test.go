@@ -0,0 +1,169 @@
+package bc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestMerkleProofsForIndices(t *testing.T) {
+	t.Parallel()
+
+	txids := []string{
+		"b6d4d13aa08bb4b6cdb3b329cef29b5a5d55d85a85c330d56fddbce78d99c7d6",
+		"426f65f6a6ce79c909e54d8959c874a767db3076e76031be70942b896cc64052",
+		"adc23d36cc457d5847968c2e4d5f017a6f12a2f165102d10d2843f5276cfe68e",
+		"728714bbbddd81a54cae473835ae99eb92ed78191327eb11a9d7494273dcad2a",
+		"e3aa0230aa81abd483023886ad12790acf070e2a9f92d7f0ae3bebd90a904361",
+		"4848b9e94dd0e4f3173ebd6982ae7eb6b793de305d8450624b1d86c02a5c61d9",
+		"912f77eefdd311e24f96850ed8e701381fc4943327f9cf73f9c4dec0d93a056d",
+		"397fe2ae4d1d24efcc868a02daae42d1b419289d9a1ded3a5fe771efcc1219d9",
+	}
+
+	proofs, err := bc.MerkleProofsForIndices(txids, []uint64{5}, "merkleRoot")
+	assert.NoError(t, err)
+	assert.Equal(t, []*bc.MerkleProof{{
+		Index:      5,
+		TxOrID:     "4848b9e94dd0e4f3173ebd6982ae7eb6b793de305d8450624b1d86c02a5c61d9",
+		Target:     "1a1e779cd7dfc59f603b4e88842121001af822b2dc5d3b167ae66152e586a6b0",
+		TargetType: "merkleRoot",
+		Nodes: []string{
+			"e3aa0230aa81abd483023886ad12790acf070e2a9f92d7f0ae3bebd90a904361",
+			"f46309558d8701efa4b7c1b00b62af694e2a5c6719d21bd43f7167c8e9d12fc0",
+			"39e5c80bad47d33ac369c2e4341b81b07821488dab75f4bc1651d3c3bf182a56",
+		},
+	}}, proofs)
+}
+
+func TestMerkleProofsForIndices_AllIndices(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 3, 5, 11, 1500} {
+		_, txids := testTxIDs(n)
+		root, err := bc.BuildMerkleRoot(txids)
+		assert.NoError(t, err)
+
+		indices := make([]uint64, n)
+		for i := range indices {
+			indices[i] = uint64(i)
+		}
+		proofs, err := bc.MerkleProofsForIndices(txids, indices, "merkleRoot")
+		assert.NoError(t, err)
+		assert.Len(t, proofs, n)
+
+		for i, mp := range proofs {
+			assert.Equal(t, txids[i], mp.TxOrID)
+			assert.Equal(t, root, mp.Target)
+
+			tmp, err := mp.Typed()
+			assert.NoError(t, err)
+			calculated, err := tmp.MerkleRoot()
+			assert.NoError(t, err)
+			assert.Equal(t, root, calculated.String(), "%d txids, index %d", n, i)
+		}
+	}
+}
+
+func TestMerkleProofsForIndices_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, txids := testTxIDs(3)
+
+	tests := map[string]struct {
+		txids      []string
+		indices    []uint64
+		targetType string
+		expErr     error
+	}{
+		"index out of range": {
+			txids:      txids,
+			indices:    []uint64{1, 3},
+			targetType: "merkleRoot",
+			expErr:     bc.ErrTxIndexOutOfRange,
+		},
+		"block hash target": {
+			txids:      txids,
+			indices:    []uint64{1},
+			targetType: "hash",
+			expErr:     bc.ErrTargetRequiresHeader,
+		},
+		"header target": {
+			txids:      txids,
+			indices:    []uint64{1},
+			targetType: "header",
+			expErr:     bc.ErrTargetRequiresHeader,
+		},
+		"unknown target type": {
+			txids:      txids,
+			indices:    []uint64{1},
+			targetType: "block",
+			expErr:     bc.ErrInvalidTargetType,
+		},
+		"invalid txid": {
+			txids:      []string{txids[0], "abc"},
+			indices:    []uint64{1},
+			targetType: "merkleRoot",
+			expErr:     bc.ErrInvalidHashLength,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bc.MerkleProofsForIndices(test.txids, test.indices, test.targetType)
+			assert.True(t, errors.Is(err, test.expErr), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestBlock_MerkleProofs(t *testing.T) {
+	t.Parallel()
+
+	b, err := bc.NewBlockFromStr(testBlockStr)
+	assert.NoError(t, err)
+	hash, err := b.BlockHeader.Hash()
+	assert.NoError(t, err)
+	root, err := bc.NewHashFromDisplayBytes(b.BlockHeader.HashMerkleRoot)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		targetType string
+		expTarget  string
+	}{
+		"block hash": {
+			expTarget: hash.String(),
+		},
+		"header": {
+			targetType: "header",
+			expTarget:  b.BlockHeader.String(),
+		},
+		"merkle root": {
+			targetType: "merkleRoot",
+			expTarget:  root.String(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			proofs, err := b.MerkleProofs([]uint64{2, 0}, test.targetType)
+			assert.NoError(t, err)
+			assert.Len(t, proofs, 2)
+
+			for i, idx := range []uint64{2, 0} {
+				mp := proofs[i]
+				assert.Equal(t, idx, mp.Index)
+				assert.Equal(t, b.Txs[idx].TxID(), mp.TxOrID)
+				assert.Equal(t, test.targetType, mp.TargetType)
+				assert.Equal(t, test.expTarget, mp.Target)
+
+				tmp, err := mp.Typed()
+				assert.NoError(t, err)
+				calculated, err := tmp.MerkleRoot()
+				assert.NoError(t, err)
+				assert.Equal(t, root, calculated)
+			}
+		})
+	}
+}
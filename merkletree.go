@@ -0,0 +1,87 @@
+package bc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelThreshold is the number of items below which work is done on the calling
+// goroutine, as fanning it out would cost more than it saves.
+const parallelThreshold = 1 << 10
+
+// BuildTypedMerkleRoot returns the merkle root of txids, which are in wire order. It
+// gives the same root as BuildMerkleRoot but hashes each level of the tree across
+// multiple goroutines, so is suitable for blocks with millions of transactions.
+//
+// The zero Hash is returned if txids is empty.
+func BuildTypedMerkleRoot(txids []Hash) Hash {
+	if len(txids) == 0 {
+		return Hash{}
+	}
+	merkles := BuildTypedMerkleTreeStore(txids)
+
+	return merkles[len(merkles)-1]
+}
+
+// BuildTypedMerkleTreeStore creates a merkle tree from txids, which are in wire order,
+// laid out in a linear array in the same way as BuildMerkleTreeStore. Parent nodes
+// with no children are the zero Hash rather than an empty string.
+//
+// Each level of the tree is hashed across multiple goroutines once it is large enough
+// to benefit.
+func BuildTypedMerkleTreeStore(txids []Hash) []Hash {
+	if len(txids) == 0 {
+		return nil
+	}
+
+	nextPoT := nextPowerOfTwo(len(txids))
+	merkles := make([]Hash, nextPoT*2-1)
+	copy(merkles, txids)
+
+	// offset is the start of the current level, width its size including empty nodes
+	// and n the number of nodes in it which aren't empty.
+	for offset, width, n := 0, nextPoT, len(txids); width > 1; offset, width, n = offset+width, width/2, (n+1)/2 {
+		level := merkles[offset : offset+width]
+		parents := merkles[offset+width : offset+width+width/2]
+		parallelFor((n+1)/2, func(start, end int) {
+			for i := start; i < end; i++ {
+				l := level[i*2]
+				r := l
+				// When there is no right child, the parent is generated by
+				// hashing the concatenation of the left child with itself.
+				if i*2+1 < n {
+					r = level[i*2+1]
+				}
+				parents[i] = TypedMerkleTreeParent(l, r)
+			}
+		})
+	}
+
+	return merkles
+}
+
+// parallelFor calls fn over contiguous ranges covering [0, n), spread across
+// GOMAXPROCS goroutines when n is at least parallelThreshold. It returns once
+// every call has returned.
+func parallelFor(n int, fn func(start, end int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if n < parallelThreshold || workers < 2 {
+		fn(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
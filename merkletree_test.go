@@ -0,0 +1,63 @@
+package bc_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+// testTxIDs returns n distinct txids along with their display strings.
+func testTxIDs(n int) ([]bc.Hash, []string) {
+	txids := make([]bc.Hash, n)
+	strs := make([]string, n)
+	for i := range txids {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(i))
+		txids[i] = bc.Sha256dHash(b)
+		strs[i] = txids[i].String()
+	}
+
+	return txids, strs
+}
+
+func TestBuildTypedMerkleRoot(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 3, 7, 8, 9, 3001} {
+		txids, strs := testTxIDs(n)
+		expected, err := bc.BuildMerkleRoot(strs)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, bc.BuildTypedMerkleRoot(txids).String(), "%d txids", n)
+	}
+
+	assert.True(t, bc.BuildTypedMerkleRoot(nil).IsZero())
+}
+
+func TestBuildTypedMerkleTreeStore(t *testing.T) {
+	t.Parallel()
+
+	txids, strs := testTxIDs(5)
+	expected, err := bc.BuildMerkleTreeStore(strs)
+	assert.NoError(t, err)
+
+	merkles := bc.BuildTypedMerkleTreeStore(txids)
+	assert.Len(t, merkles, len(expected))
+	for i, h := range merkles {
+		if expected[i] == "" {
+			assert.True(t, h.IsZero(), "node %d", i)
+			continue
+		}
+		assert.Equal(t, expected[i], h.String(), "node %d", i)
+	}
+}
+
+func BenchmarkBuildTypedMerkleRoot(b *testing.B) {
+	txids, _ := testTxIDs(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bc.BuildTypedMerkleRoot(txids)
+	}
+}
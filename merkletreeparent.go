@@ -38,3 +38,13 @@ func MerkleTreeParent(leftNode, rightNode []byte) []byte {
 	// swap endianness at the end and convert to hex string
 	return bt.ReverseBytes(hash)
 }
+
+// TypedMerkleTreeParent returns the Merkle Tree parent of two Merkle
+// Tree children.
+func TypedMerkleTreeParent(leftNode, rightNode Hash) Hash {
+	concat := make([]byte, 0, HashSize*2)
+	concat = append(concat, leftNode[:]...)
+	concat = append(concat, rightNode[:]...)
+
+	return Sha256dHash(concat)
+}
@@ -31,3 +31,12 @@ func TestGetMerkleTreeParent(t *testing.T) {
 
 	assert.Equal(t, expected, parent)
 }
+
+func TestTypedMerkleTreeParent(t *testing.T) {
+	leftNode, _ := bc.NewHashFromString("d6c79a6ef05572f0cb8e9a450c561fc40b0a8a7d48faad95e20d93ddeb08c231")
+	rightNode, _ := bc.NewHashFromString("b1ed931b79056438b990d8981ba46fae97e5574b142445a74a44b978af284f98")
+
+	parent := bc.TypedMerkleTreeParent(leftNode, rightNode)
+
+	assert.Equal(t, "b0d537b3ee52e472507f453df3d69561720346118a5a8c4d85ca0de73bc792be", parent.String())
+}
@@ -0,0 +1,270 @@
+package bc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ErrUnexpectedBits is returned when a header's bits don't match the difficulty required
+// by the retarget rules of the network.
+var ErrUnexpectedBits = errors.New("header bits don't match required difficulty")
+
+// A HeaderBranch gives access to the headers preceding a header which is being
+// validated, the last header of the branch being its parent.
+type HeaderBranch interface {
+	// Height returns the height of the last header of the branch.
+	Height() uint32
+	// HeaderAtHeight returns the header of the branch at the height provided.
+	HeaderAtHeight(height uint32) (*BlockHeader, error)
+}
+
+// HeaderSequence is a HeaderBranch made from consecutive headers, the first of
+// which is at StartHeight.
+type HeaderSequence struct {
+	StartHeight uint32
+	Headers     []*BlockHeader
+}
+
+// Height returns the height of the last header in the sequence.
+func (s *HeaderSequence) Height() uint32 {
+	return s.StartHeight + uint32(len(s.Headers)) - 1
+}
+
+// HeaderAtHeight returns the header of the sequence at the height provided.
+func (s *HeaderSequence) HeaderAtHeight(height uint32) (*BlockHeader, error) {
+	if height < s.StartHeight || int(height-s.StartHeight) >= len(s.Headers) {
+		return nil, fmt.Errorf("%w: no header at height %d", ErrHeaderNotFound, height)
+	}
+
+	return s.Headers[height-s.StartHeight], nil
+}
+
+// CheckHeaderBits returns ErrUnexpectedBits if the bits of the header, which extends
+// the branch provided, aren't those required by the retarget rules in params.
+func CheckHeaderBits(params *ChainParams, branch HeaderBranch, bh *BlockHeader) error {
+	if len(bh.Bits) != 4 {
+		return fmt.Errorf("%w: bits should be 4 bytes", ErrMalformedHeader)
+	}
+
+	bits, err := NextWorkRequired(params, branch, bh)
+	if err != nil {
+		return err
+	}
+
+	if got := binary.BigEndian.Uint32(bh.Bits); got != bits {
+		return fmt.Errorf("%w: expected %08x got %08x", ErrUnexpectedBits, bits, got)
+	}
+
+	return nil
+}
+
+// NextWorkRequired returns the bits required of the header, which extends the
+// branch provided, under the retarget rules in params.
+//
+// Before DAAHeight this is the original 2016 block retarget together with the
+// emergency difficulty adjustment, after it is the cw-144 algorithm. The testnet
+// minimum difficulty rule is applied to both when the params allow it.
+//
+// See https://github.com/bitcoin-sv/bitcoin-sv/blob/master/src/pow.cpp
+func NextWorkRequired(params *ChainParams, branch HeaderBranch, bh *BlockHeader) (uint32, error) {
+	height := branch.Height()
+	prev, err := branch.HeaderAtHeight(height)
+	if err != nil {
+		return 0, err
+	}
+
+	if params.NoRetargeting {
+		return headerBits(prev), nil
+	}
+
+	if height >= params.DAAHeight {
+		return cashWorkRequired(params, branch, prev, bh)
+	}
+
+	return edaWorkRequired(params, branch, prev, bh)
+}
+
+// edaWorkRequired applies the original retarget every adjustment interval and the
+// emergency difficulty adjustment in between.
+func edaWorkRequired(params *ChainParams, branch HeaderBranch, prev, bh *BlockHeader) (uint32, error) {
+	height := branch.Height()
+	nextHeight := height + 1
+	interval := params.adjustmentInterval()
+
+	// Only change once per difficulty adjustment interval.
+	if nextHeight%interval == 0 {
+		first, err := branch.HeaderAtHeight(nextHeight - interval)
+		if err != nil {
+			return 0, err
+		}
+
+		return calculateNextWorkRequired(params, prev, first.Time), nil
+	}
+
+	if params.AllowMinDifficultyBlocks {
+		// If the new block's timestamp is more than 2 * TargetSpacing after its parent
+		// then allow mining of a minimum difficulty block.
+		if minDifficultyAllowed(params, prev, bh) {
+			return params.PowLimitBits, nil
+		}
+
+		// Return the last non-special-min-difficulty-rules block.
+		for header := prev; ; {
+			if height == 0 || height%interval == 0 || headerBits(header) != params.PowLimitBits {
+				return headerBits(header), nil
+			}
+
+			height--
+			var err error
+			if header, err = branch.HeaderAtHeight(height); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// We can't go below the minimum, so bail early.
+	bits := headerBits(prev)
+	if bits == params.PowLimitBits || nextHeight < 7 {
+		return bits, nil
+	}
+
+	// If producing the last 6 blocks took less than 12h, keep the same difficulty.
+	mtp, err := MedianTimePast(branch, height)
+	if err != nil {
+		return 0, err
+	}
+	mtp6, err := MedianTimePast(branch, nextHeight-7)
+	if err != nil {
+		return 0, err
+	}
+	if int64(mtp)-int64(mtp6) < int64(12*time.Hour/time.Second) {
+		return bits, nil
+	}
+
+	// Otherwise increase the target by 1/4, reducing the difficulty by 20%.
+	target := CompactToBig(bits)
+	target.Add(target, new(big.Int).Rsh(target, 2))
+	if target.Cmp(params.PowLimit) > 0 {
+		return params.PowLimitBits, nil
+	}
+
+	return BigToCompact(target), nil
+}
+
+// calculateNextWorkRequired scales the target of prev by the time taken to mine the
+// last adjustment interval, limited to a factor of 4 either way.
+func calculateNextWorkRequired(params *ChainParams, prev *BlockHeader, firstTime uint32) uint32 {
+	timespan := int64(params.TargetTimespan / time.Second)
+	actual := int64(prev.Time) - int64(firstTime)
+	if actual < timespan/4 {
+		actual = timespan / 4
+	}
+	if actual > timespan*4 {
+		actual = timespan * 4
+	}
+
+	target := CompactToBig(headerBits(prev))
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(timespan))
+	if target.Cmp(params.PowLimit) > 0 {
+		return params.PowLimitBits
+	}
+
+	return BigToCompact(target)
+}
+
+// cashWorkRequired applies the cw-144 difficulty adjustment algorithm, which targets
+// the work done over the last 144 blocks being produced in 144 * TargetSpacing.
+func cashWorkRequired(params *ChainParams, branch HeaderBranch, prev, bh *BlockHeader) (uint32, error) {
+	if params.AllowMinDifficultyBlocks && minDifficultyAllowed(params, prev, bh) {
+		return params.PowLimitBits, nil
+	}
+
+	height := branch.Height()
+	if height < params.adjustmentInterval() {
+		return 0, fmt.Errorf("%w: cw-144 requires %d previous headers", ErrHeaderNotFound, params.adjustmentInterval())
+	}
+
+	lastHeight, last, err := suitableBlock(branch, height)
+	if err != nil {
+		return 0, err
+	}
+	firstHeight, first, err := suitableBlock(branch, height-144)
+	if err != nil {
+		return 0, err
+	}
+
+	// From the total work done and the time it took to produce it, deduce how much
+	// work is expected to be produced in the targeted time between blocks.
+	work := big.NewInt(0)
+	for h := firstHeight + 1; h <= lastHeight; h++ {
+		header, err := branch.HeaderAtHeight(h)
+		if err != nil {
+			return 0, err
+		}
+		work.Add(work, CalcWork(headerBits(header)))
+	}
+	spacing := int64(params.TargetSpacing / time.Second)
+	work.Mul(work, big.NewInt(spacing))
+
+	// Bound the amplitude of the adjustment to a factor in [0.5, 2] to avoid
+	// difficulty cliffs.
+	actual := int64(last.Time) - int64(first.Time)
+	if actual > 288*spacing {
+		actual = 288 * spacing
+	} else if actual < 72*spacing {
+		actual = 72 * spacing
+	}
+	work.Div(work, big.NewInt(actual))
+
+	// The target is (2^256 / work) - 1, computed as (2^256 - work) / work.
+	target := new(big.Int).Lsh(big.NewInt(1), 256)
+	target.Sub(target, work)
+	target.Div(target, work)
+	if target.Cmp(params.PowLimit) > 0 {
+		return params.PowLimitBits, nil
+	}
+
+	return BigToCompact(target), nil
+}
+
+// suitableBlock returns the header with the median timestamp of the header at height
+// and its two parents, limiting the influence of a single skewed timestamp.
+func suitableBlock(branch HeaderBranch, height uint32) (uint32, *BlockHeader, error) {
+	var heights [3]uint32
+	var headers [3]*BlockHeader
+	for i := 0; i < 3; i++ {
+		h := height - uint32(2-i)
+		header, err := branch.HeaderAtHeight(h)
+		if err != nil {
+			return 0, nil, err
+		}
+		heights[i], headers[i] = h, header
+	}
+
+	// Sorting network, the same as the node uses so that ties resolve identically.
+	swap := func(i, j int) {
+		if headers[i].Time > headers[j].Time {
+			heights[i], heights[j] = heights[j], heights[i]
+			headers[i], headers[j] = headers[j], headers[i]
+		}
+	}
+	swap(0, 2)
+	swap(0, 1)
+	swap(1, 2)
+
+	return heights[1], headers[1], nil
+}
+
+// minDifficultyAllowed reports whether bh is more than twice the target spacing
+// after prev, allowing it to be mined at the lowest difficulty on test networks.
+func minDifficultyAllowed(params *ChainParams, prev, bh *BlockHeader) bool {
+	return int64(bh.Time) > int64(prev.Time)+2*int64(params.TargetSpacing/time.Second)
+}
+
+func headerBits(bh *BlockHeader) uint32 {
+	return binary.BigEndian.Uint32(bh.Bits)
+}
@@ -0,0 +1,184 @@
+package bc_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/libsv/go-bc"
+)
+
+// headerSequence builds count headers from height 0 with the bits provided and the
+// timestamp of each header given by timeAt.
+func headerSequence(count int, bits uint32, timeAt func(height int) uint32) *bc.HeaderSequence {
+	s := &bc.HeaderSequence{}
+	for i := 0; i < count; i++ {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, bits)
+		s.Headers = append(s.Headers, &bc.BlockHeader{
+			Time:           timeAt(i),
+			Bits:           b,
+			HashPrevBlock:  make([]byte, 32),
+			HashMerkleRoot: make([]byte, 32),
+		})
+	}
+
+	return s
+}
+
+func headerWithBits(bits uint32, time uint32) *bc.BlockHeader {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, bits)
+
+	return &bc.BlockHeader{
+		Time:           time,
+		Bits:           b,
+		HashPrevBlock:  make([]byte, 32),
+		HashMerkleRoot: make([]byte, 32),
+	}
+}
+
+func TestNextWorkRequired(t *testing.T) {
+	t.Parallel()
+
+	const start = 1500000000
+	tests := map[string]struct {
+		params  *bc.ChainParams
+		branch  *bc.HeaderSequence
+		time    uint32
+		expBits uint32
+	}{
+		"regtest never retargets": {
+			params:  bc.RegTestParams,
+			branch:  headerSequence(2016, 0x207fffff, func(h int) uint32 { return start + uint32(h) }),
+			time:    start + 2016,
+			expBits: 0x207fffff,
+		},
+		"mainnet keeps bits between retargets": {
+			params:  bc.MainNetParams,
+			branch:  headerSequence(100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*600 }),
+			time:    start + 100*600,
+			expBits: 0x1c7fff80,
+		},
+		"mainnet retarget halves target when interval took half the timespan": {
+			params: bc.MainNetParams,
+			branch: headerSequence(2016, 0x1d00ffff, func(h int) uint32 {
+				if h == 2015 {
+					return start + 7*24*60*60
+				}
+				return start + uint32(h)*300
+			}),
+			time:    start + 7*24*60*60 + 300,
+			expBits: 0x1c7fff80,
+		},
+		"mainnet retarget is limited to a factor of 4": {
+			params:  bc.MainNetParams,
+			branch:  headerSequence(2016, 0x1d00ffff, func(h int) uint32 { return start + uint32(h) }),
+			time:    start + 2016,
+			expBits: 0x1c3fffc0,
+		},
+		"mainnet retarget never exceeds the pow limit": {
+			params:  bc.MainNetParams,
+			branch:  headerSequence(2016, 0x1d00ffff, func(h int) uint32 { return start + uint32(h)*6000 }),
+			time:    start + 2016*6000,
+			expBits: 0x1d00ffff,
+		},
+		"emergency adjustment lowers difficulty when 6 blocks took over 12 hours": {
+			params:  bc.MainNetParams,
+			branch:  headerSequence(100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*3*60*60 }),
+			time:    start + 100*3*60*60,
+			expBits: 0x1d009fff,
+		},
+		"testnet allows min difficulty after 20 minutes": {
+			params:  bc.TestNetParams,
+			branch:  headerSequence(100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*600 }),
+			time:    start + 99*600 + 1201,
+			expBits: 0x1d00ffff,
+		},
+		"testnet returns last block not mined at min difficulty": {
+			params: bc.TestNetParams,
+			branch: func() *bc.HeaderSequence {
+				s := headerSequence(100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*600 })
+				for _, bh := range s.Headers[90:] {
+					binary.BigEndian.PutUint32(bh.Bits, 0x1d00ffff)
+				}
+				return s
+			}(),
+			time:    start + 100*600,
+			expBits: 0x1c7fff80,
+		},
+		"cw-144 keeps difficulty when blocks are on time": {
+			params: &bc.ChainParams{
+				PowLimit:       bc.MainNetParams.PowLimit,
+				PowLimitBits:   0x1d00ffff,
+				TargetTimespan: bc.MainNetParams.TargetTimespan,
+				TargetSpacing:  bc.MainNetParams.TargetSpacing,
+				DAAHeight:      0,
+			},
+			branch:  headerSequence(2100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*600 }),
+			time:    start + 2100*600,
+			expBits: 0x1c7fff80,
+		},
+		"cw-144 halves target when blocks take half the time": {
+			params: &bc.ChainParams{
+				PowLimit:       bc.MainNetParams.PowLimit,
+				PowLimitBits:   0x1d00ffff,
+				TargetTimespan: bc.MainNetParams.TargetTimespan,
+				TargetSpacing:  bc.MainNetParams.TargetSpacing,
+				DAAHeight:      0,
+			},
+			branch:  headerSequence(2100, 0x1c7fff80, func(h int) uint32 { return start + uint32(h)*300 }),
+			time:    start + 2100*300,
+			expBits: 0x1c3fffc0,
+		},
+		"cw-144 testnet allows min difficulty after 20 minutes": {
+			params:  bc.TestNetParams,
+			branch:  &bc.HeaderSequence{StartHeight: 1188697, Headers: []*bc.BlockHeader{headerWithBits(0x1c7fff80, start)}},
+			time:    start + 1201,
+			expBits: 0x1d00ffff,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bits, err := bc.NextWorkRequired(test.params, test.branch, headerWithBits(0, test.time))
+			assert.NoError(t, err)
+			assert.Equal(t, test.expBits, bits, "expected %08x got %08x", test.expBits, bits)
+
+			err = bc.CheckHeaderBits(test.params, test.branch, headerWithBits(test.expBits, test.time))
+			assert.NoError(t, err)
+
+			err = bc.CheckHeaderBits(test.params, test.branch, headerWithBits(test.expBits-1, test.time))
+			assert.True(t, errors.Is(err, bc.ErrUnexpectedBits))
+		})
+	}
+}
+
+func TestNextWorkRequired_MissingHeaders(t *testing.T) {
+	// cw-144 needs the previous 147 headers, which aren't in this branch.
+	branch := &bc.HeaderSequence{StartHeight: 600000, Headers: []*bc.BlockHeader{headerWithBits(0x18000000, 1500000000)}}
+	_, err := bc.NextWorkRequired(bc.MainNetParams, branch, headerWithBits(0x18000000, 1500000600))
+	assert.True(t, errors.Is(err, bc.ErrHeaderNotFound))
+}
+
+func TestMemoryBlockHeaderChain_VerifyDifficulty(t *testing.T) {
+	t.Parallel()
+
+	c, err := bc.NewMemoryBlockHeaderChain(bc.RegTestParams)
+	assert.NoError(t, err)
+
+	headers := mineBranch(t, regtestGenesis, 3, "main")
+	for _, bh := range headers {
+		assert.NoError(t, c.AddHeader(bh))
+	}
+
+	bh := mineHeader(t, headers[2], "harder")
+	bh.Bits = []byte{0x20, 0x7f, 0xff, 0xfe}
+	for !bh.Valid() {
+		bh.Nonce++
+	}
+	err = c.AddHeader(bh)
+	assert.True(t, errors.Is(err, bc.ErrUnexpectedBits))
+}
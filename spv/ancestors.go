@@ -3,6 +3,8 @@ package spv
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/tokenized/go-bc"
 	"github.com/tokenized/go-bt"
@@ -28,18 +30,21 @@ type MerkleProofStore interface {
 //
 // NOTE: this is the JSON format of the Ancestry but in a nested format (in comparison) with
 // the flat structure that the TSC uses. This allows verification to become a lot easier and
-// use a recursive function.
+// use a recursive function. Ancestors.MarshalFlatJSON and UnmarshalFlatJSON convert to and
+// from the flat structure.
 type Ancestor struct {
 	Tx            *bt.Tx                       `bsor:"1" json:"tx,omitempty"`
 	Proof         *bc.MerkleProof              `bsor:"2" json:"proof,omitempty"`
 	MapiResponses []json_envelope.JSONEnvelope `bsor:"3" json:"mapiResponses,omitempty"`
+	// MerklePath is a BUMP merkle path which may be provided in place of Proof.
+	MerklePath *bc.MerklePath `bsor:"4" json:"merklePath,omitempty"`
 }
 
 type Ancestors []*Ancestor
 
-// IsAnchored returns true if the ancestry has a merkle proof.
+// IsAnchored returns true if the ancestry has a merkle proof or merkle path.
 func (e *Ancestor) IsAnchored() bool {
-	return e.Proof != nil
+	return e.Proof != nil || e.MerklePath != nil
 }
 
 // Ancestor will return a ancestor if found otherwise a ErrNotAllInputsSupplied error is returned.
@@ -53,54 +58,273 @@ func (e Ancestors) Ancestor(txID bitcoin.Hash32) (*Ancestor, error) {
 	return nil, errors.Wrapf(ErrNotAllInputsSupplied, "expected parent tx %s is missing", txID)
 }
 
-// Populate populates the ancestors for a provided tx's inputs.
+// The default limits of Ancestors.Populate.
+const (
+	DefaultPopulateMaxDepth = 1000
+	DefaultPopulateMaxTxs   = 10000
+	DefaultPopulateMaxBytes = 100 << 20
+	DefaultPopulateWorkers  = 8
+)
+
+type populateOptions struct {
+	maxDepth int
+	maxTxs   int
+	maxBytes int
+	workers  int
+}
+
+// PopulateOpt defines a functional option that is used to modify the limits of
+// Ancestors.Populate.
+type PopulateOpt func(opts *populateOptions)
+
+// PopulateMaxDepth limits the number of generations of unconfirmed ancestors walked,
+// with ErrAncestryTooDeep returned if a tx is still unanchored at that depth.
+func PopulateMaxDepth(depth int) PopulateOpt {
+	return func(opts *populateOptions) {
+		opts.maxDepth = depth
+	}
+}
+
+// PopulateMaxTxs limits the number of ancestors fetched, with ErrAncestryTooManyTxs
+// returned if more are needed.
+func PopulateMaxTxs(txs int) PopulateOpt {
+	return func(opts *populateOptions) {
+		opts.maxTxs = txs
+	}
+}
+
+// PopulateMaxBytes limits the total size of the ancestor txs fetched, with
+// ErrAncestryTooLarge returned and the remaining fetches cancelled as soon as they
+// exceed it.
+func PopulateMaxBytes(size int) PopulateOpt {
+	return func(opts *populateOptions) {
+		opts.maxBytes = size
+	}
+}
+
+// PopulateWorkers sets the number of parents fetched at once from the stores.
+func PopulateWorkers(workers int) PopulateOpt {
+	return func(opts *populateOptions) {
+		opts.workers = workers
+	}
+}
+
+// Populate populates the ancestors for a provided tx's inputs, fetching each parent from
+// the txStore along with its merkle proof from the mpStore, and walking on through the
+// parents of those without a proof until every path reaches an anchored ancestor.
+//
+// The ancestry is walked a generation at a time, fetching the parents of a generation
+// concurrently, and each txid is only fetched once however many inputs spend it. The
+// walk is bounded by the limits of the opts, which default to DefaultPopulateMaxDepth,
+// DefaultPopulateMaxTxs, DefaultPopulateMaxBytes and DefaultPopulateWorkers, and stops
+// when ctx is cancelled.
 func (a *Ancestors) Populate(ctx context.Context, txStore TxStore, mpStore MerkleProofStore,
-	tx *bt.Tx) error {
+	tx *bt.Tx, opts ...PopulateOpt) error {
 
-	for _, input := range tx.Inputs {
-		pTxID := bt.ReverseBytes(input.PreviousTxID())
-		txid, _ := bitcoin.NewHash32(pTxID)
+	o := &populateOptions{
+		maxDepth: DefaultPopulateMaxDepth,
+		maxTxs:   DefaultPopulateMaxTxs,
+		maxBytes: DefaultPopulateMaxBytes,
+		workers:  DefaultPopulateWorkers,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
 
-		_, err := a.Ancestor(*txid)
-		if err == nil {
-			continue // already have this tx
-		}
+	// seen holds every txid already in the ancestry or scheduled to be fetched. The tx
+	// itself is included so that a store returning it as its own ancestor can't
+	// create a cycle.
+	seen := map[bitcoin.Hash32]bool{*tx.TxHash(): true}
+	for _, ancestor := range *a {
+		seen[*ancestor.Tx.TxHash()] = true
+	}
 
-		if errors.Cause(err) != ErrNotAllInputsSupplied {
-			return errors.Wrap(err, "ancestor")
+	var txs int
+	var size int64
+	generation := unseenParents(tx, seen)
+	for depth := 1; len(generation) > 0; depth++ {
+		if depth > o.maxDepth {
+			return errors.Wrapf(ErrAncestryTooDeep, "unanchored after %d generations", o.maxDepth)
+		}
+		txs += len(generation)
+		if txs > o.maxTxs {
+			return errors.Wrapf(ErrAncestryTooManyTxs, "more than %d txs", o.maxTxs)
 		}
 
-		// Build a *bt.Tx from its TxID and recursively call this function building
-		// for inputs without proofs, until a parent with a Merkle Proof is found.
-		pTx, err := txStore.Tx(ctx, *txid)
+		ancestors, err := fetchAncestors(ctx, txStore, mpStore, generation, o.workers, &size,
+			o.maxBytes)
 		if err != nil {
-			return errors.Wrapf(err, "failed to get tx %s", txid)
-		}
-		if pTx == nil {
-			return fmt.Errorf("could not find tx %s", txid)
+			return err
 		}
 
-		// Check the store for a Merkle Proof for the current input.
-		mp, err := mpStore.MerkleProof(ctx, *txid)
-		if err != nil {
-			return errors.Wrapf(err, "failed to get merkle proof for tx %s", txid)
+		var next []bitcoin.Hash32
+		for _, ancestor := range ancestors {
+			*a = append(*a, ancestor)
+			if !ancestor.IsAnchored() {
+				next = append(next, unseenParents(ancestor.Tx, seen)...)
+			}
 		}
-		// If a Merkle Proof is found, create the ancestry and skip any further recursion
-		if mp != nil {
-			*a = append(*a, &Ancestor{
-				Tx:    pTx,
-				Proof: mp,
-			})
+		generation = next
+	}
 
+	return nil
+}
+
+// unseenParents returns the txids of the parents of tx which aren't in seen, adding
+// them to it.
+func unseenParents(tx *bt.Tx, seen map[bitcoin.Hash32]bool) []bitcoin.Hash32 {
+	var txids []bitcoin.Hash32
+	for _, input := range tx.Inputs {
+		txid, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+		if err != nil || seen[*txid] {
 			continue
 		}
+		seen[*txid] = true
+		txids = append(txids, *txid)
+	}
+
+	return txids
+}
+
+// fetchAncestors fetches the ancestors of txids across the number of workers, returning
+// them in the order of txids. The size of each tx fetched is added to size, and
+// ErrAncestryTooLarge is returned as soon as it exceeds maxBytes. The first error stops
+// the fetching and is returned.
+func fetchAncestors(ctx context.Context, txStore TxStore, mpStore MerkleProofStore,
+	txids []bitcoin.Hash32, workers int, size *int64, maxBytes int) ([]*Ancestor, error) {
+
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		if err := a.Populate(ctx, txStore, mpStore, pTx); err != nil {
-			return errors.Wrap(err, pTx.TxHash().String())
+	ancestors := make([]*Ancestor, len(txids))
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	if workers > len(txids) {
+		workers = len(txids)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				ancestor, err := fetchAncestor(fetchCtx, txStore, mpStore, txids[i])
+				if err != nil {
+					fail(err)
+					continue
+				}
+				if atomic.AddInt64(size, int64(len(ancestor.Tx.Bytes()))) > int64(maxBytes) {
+					fail(errors.Wrapf(ErrAncestryTooLarge, "more than %d bytes", maxBytes))
+					continue
+				}
+				ancestors[i] = ancestor
+			}
+		}()
+	}
+
+feed:
+	for i := range txids {
+		select {
+		case jobs <- i:
+		case <-fetchCtx.Done():
+			break feed
 		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	return nil
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "populate")
+	}
+
+	return ancestors, nil
+}
+
+// fetchAncestor fetches the tx of txid and its merkle proof, if it has one.
+func fetchAncestor(ctx context.Context, txStore TxStore, mpStore MerkleProofStore,
+	txid bitcoin.Hash32) (*Ancestor, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "populate")
+	}
+
+	tx, err := txStore.Tx(ctx, txid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tx %s", txid)
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("could not find tx %s", txid)
+	}
+	if !tx.TxHash().Equal(&txid) {
+		return nil, errors.Wrapf(ErrTxIDMismatch, "tx store returned tx %s for %s", tx.TxHash(), txid)
+	}
+
+	mp, err := mpStore.MerkleProof(ctx, txid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get merkle proof for tx %s", txid)
+	}
+
+	return &Ancestor{
+		Tx:    tx,
+		Proof: mp,
+	}, nil
+}
+
+// Minimize returns the smallest ancestry proving tx, holding only the ancestors on a
+// path from tx to its nearest anchored ancestors, along with the ancestors removed. The
+// ancestors keep their order.
+//
+// Ancestors of anchored ancestors and those tx doesn't descend from are removed. A
+// missing ancestor doesn't stop the walk, it is left to verification to report.
+func (e Ancestors) Minimize(tx *bt.Tx) (Ancestors, Ancestors) {
+	needed := make(map[bitcoin.Hash32]bool)
+	var walk func(tx *bt.Tx)
+	walk = func(tx *bt.Tx) {
+		for _, input := range tx.Inputs {
+			txid, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+			if err != nil || needed[*txid] {
+				continue
+			}
+			parent, err := e.Ancestor(*txid)
+			if err != nil {
+				continue
+			}
+			needed[*txid] = true
+			if !parent.IsAnchored() {
+				walk(parent.Tx)
+			}
+		}
+	}
+	walk(tx)
+
+	kept := make(Ancestors, 0, len(needed))
+	var removed Ancestors
+	for _, ancestor := range e {
+		if needed[*ancestor.Tx.TxHash()] {
+			kept = append(kept, ancestor)
+			// a duplicate of an ancestor is removed.
+			delete(needed, *ancestor.Tx.TxHash())
+			continue
+		}
+		removed = append(removed, ancestor)
+	}
+
+	return kept, removed
 }
 
 func (e Ancestors) Bytes() ([]byte, error) {
@@ -0,0 +1,395 @@
+package spv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/tokenized/go-bc"
+)
+
+// BEEFVersion is the version of the BEEF format, written in its first four bytes
+// as 0100BEEF.
+const BEEFVersion uint32 = 0xEFBE0001
+
+// BEEF is a Background Evaluation Extended Format (BRC-62) transaction envelope. It
+// holds the BUMP merkle paths of its confirmed transactions along with every
+// transaction needed to verify the last of them, the subject transaction.
+//
+// Txs are in topological order, with each transaction following the parents it
+// spends which are also in the BEEF.
+//
+// See https://github.com/bitcoin-sv/BRCs/blob/master/transactions/0062.md
+type BEEF struct {
+	BUMPs []*bc.MerklePath
+	Txs   []*BEEFTx
+}
+
+// A BEEFTx is a transaction of a BEEF. When HasBUMP is set the transaction is
+// confirmed, proven by the path of the BEEF at BUMPIndex.
+type BEEFTx struct {
+	Tx        *bt.Tx
+	HasBUMP   bool
+	BUMPIndex uint64
+}
+
+// NewBEEFFromStr returns the BEEF of the hex string of its binary encoding.
+func NewBEEFFromStr(s string) (*BEEF, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBEEFFromBytes(b)
+}
+
+// NewBEEFFromBytes parses a binary BEEF, checking that its bump indices are in range,
+// that each confirmed transaction is in its BUMP and that the transactions are in
+// topological order.
+//
+// ErrInvalidBEEF is returned if it is malformed and ErrUnsupportedBEEFVersion if it
+// isn't of BEEFVersion.
+func NewBEEFFromBytes(b []byte) (*BEEF, error) {
+	r := bytes.NewReader(b)
+
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, errors.Wrap(ErrInvalidBEEF, "reading version")
+	}
+	if version != BEEFVersion {
+		return nil, errors.Wrapf(ErrUnsupportedBEEFVersion, "%08x", version)
+	}
+
+	nBUMPs, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidBEEF, "reading bump count: %s", err)
+	}
+	beef := &BEEF{
+		BUMPs: make([]*bc.MerklePath, 0, minUint64(nBUMPs, 1<<10)),
+	}
+	for i := uint64(0); i < nBUMPs; i++ {
+		mp := &bc.MerklePath{}
+		if _, err := mp.ReadFrom(r); err != nil {
+			return nil, errors.Wrapf(ErrInvalidBEEF, "reading bump %d: %s", i, err)
+		}
+		beef.BUMPs = append(beef.BUMPs, mp)
+	}
+
+	nTxs, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidBEEF, "reading tx count: %s", err)
+	}
+	beef.Txs = make([]*BEEFTx, 0, minUint64(nTxs, 1<<10))
+	for i := uint64(0); i < nTxs; i++ {
+		btx, err := readBEEFTx(r)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidBEEF, "reading tx %d: %s", i, err)
+		}
+		beef.Txs = append(beef.Txs, btx)
+	}
+
+	if r.Len() > 0 {
+		return nil, errors.Wrapf(ErrInvalidBEEF, "%d unexpected bytes after last tx", r.Len())
+	}
+
+	if err := beef.validate(); err != nil {
+		return nil, err
+	}
+
+	return beef, nil
+}
+
+// readBEEFTx reads a transaction and its optional bump index.
+func readBEEFTx(r io.Reader) (*BEEFTx, error) {
+	tx := &bt.Tx{}
+	if _, err := tx.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "tx")
+	}
+
+	var hasBUMP [1]byte
+	if _, err := io.ReadFull(r, hasBUMP[:]); err != nil {
+		return nil, errors.Wrap(err, "has bump")
+	}
+
+	btx := &BEEFTx{Tx: tx}
+	switch hasBUMP[0] {
+	case 0:
+	case 1:
+		idx, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "bump index")
+		}
+		btx.HasBUMP = true
+		btx.BUMPIndex = idx
+	default:
+		return nil, errors.Errorf("invalid has bump flag %d", hasBUMP[0])
+	}
+
+	return btx, nil
+}
+
+// validate checks the bump indices are in range, each confirmed tx is in its BUMP
+// and the txs are in topological order.
+func (b *BEEF) validate() error {
+	if len(b.Txs) == 0 {
+		return errors.Wrap(ErrInvalidBEEF, "no txs")
+	}
+
+	positions := make(map[bitcoin.Hash32]int, len(b.Txs))
+	for i, btx := range b.Txs {
+		txid := *btx.Tx.TxHash()
+		if _, exists := positions[txid]; exists {
+			return errors.Wrapf(ErrInvalidBEEF, "tx %s appears twice", txid)
+		}
+		positions[txid] = i
+	}
+
+	for i, btx := range b.Txs {
+		txid := btx.Tx.TxHash()
+		if btx.HasBUMP {
+			if btx.BUMPIndex >= uint64(len(b.BUMPs)) {
+				return errors.Wrapf(ErrInvalidBEEF, "tx %s has bump index %d of %d bumps", txid,
+					btx.BUMPIndex, len(b.BUMPs))
+			}
+			if _, err := b.BUMPs[btx.BUMPIndex].CalculateRoot(bc.Hash(*txid)); err != nil {
+				return errors.Wrapf(ErrInvalidBEEF, "tx %s in bump %d: %s", txid, btx.BUMPIndex, err)
+			}
+			continue
+		}
+
+		for _, input := range btx.Tx.Inputs {
+			parent, _ := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+			if pos, exists := positions[*parent]; exists && pos >= i {
+				return errors.Wrapf(ErrInvalidBEEF, "tx %s precedes its parent %s", txid, parent)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tx returns the subject transaction of the BEEF, the last of its transactions.
+func (b *BEEF) Tx() *bt.Tx {
+	if len(b.Txs) == 0 {
+		return nil
+	}
+
+	return b.Txs[len(b.Txs)-1].Tx
+}
+
+// Bytes returns the binary encoding of the BEEF.
+func (b *BEEF) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, BEEFVersion); err != nil {
+		return nil, errors.Wrap(err, "version")
+	}
+
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(b.BUMPs))); err != nil {
+		return nil, errors.Wrap(err, "bump count")
+	}
+	for i, mp := range b.BUMPs {
+		mpb, err := mp.Bytes()
+		if err != nil {
+			return nil, errors.Wrapf(err, "bump %d", i)
+		}
+		buf.Write(mpb)
+	}
+
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(b.Txs))); err != nil {
+		return nil, errors.Wrap(err, "tx count")
+	}
+	for _, btx := range b.Txs {
+		buf.Write(btx.Tx.Bytes())
+		if !btx.HasBUMP {
+			buf.WriteByte(0)
+			continue
+		}
+		buf.WriteByte(1)
+		if err := wire.WriteVarInt(&buf, 0, btx.BUMPIndex); err != nil {
+			return nil, errors.Wrap(err, "bump index")
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// String returns the hex string of the binary encoding of the BEEF, or an empty string
+// if it can't be encoded.
+func (b *BEEF) String() string {
+	bb, err := b.Bytes()
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(bb)
+}
+
+// Ancestors returns the subject transaction of the BEEF and its ancestors, with the
+// ancestors which are confirmed holding the BUMP proving them as their MerklePath.
+//
+// ErrTipTxConfirmed is returned if the subject transaction has a BUMP.
+func (b *BEEF) Ancestors() (*bt.Tx, Ancestors, error) {
+	if len(b.Txs) == 0 {
+		return nil, nil, errors.Wrap(ErrInvalidBEEF, "no txs")
+	}
+	if b.Txs[len(b.Txs)-1].HasBUMP {
+		return nil, nil, ErrTipTxConfirmed
+	}
+
+	aa := make(Ancestors, 0, len(b.Txs)-1)
+	for _, btx := range b.Txs[:len(b.Txs)-1] {
+		a := &Ancestor{Tx: btx.Tx}
+		if btx.HasBUMP {
+			if btx.BUMPIndex >= uint64(len(b.BUMPs)) {
+				return nil, nil, errors.Wrapf(ErrInvalidBEEF, "bump index %d of %d bumps",
+					btx.BUMPIndex, len(b.BUMPs))
+			}
+			a.MerklePath = b.BUMPs[btx.BUMPIndex]
+		}
+		aa = append(aa, a)
+	}
+
+	return b.Tx(), aa, nil
+}
+
+// NewBEEFFromAncestors returns the BEEF of tx and its ancestors, ordering the
+// transactions topologically and combining the merkle paths of ancestors confirmed
+// in the same block into one BUMP.
+//
+// Confirmed ancestors must have a MerklePath, with ErrMissingMerklePath returned for
+// those with only a Proof as a TSC merkle proof doesn't hold the block height. Convert
+// them with bc.NewMerklePathFromMerkleProof first.
+//
+// A copy of tx in the ancestors is left out so that tx is always last.
+func NewBEEFFromAncestors(tx *bt.Tx, aa Ancestors) (*BEEF, error) {
+	beef := &BEEF{
+		BUMPs: []*bc.MerklePath{},
+		Txs:   make([]*BEEFTx, 0, len(aa)+1),
+	}
+
+	tipID := *tx.TxHash()
+	ancestors := make(map[bitcoin.Hash32]*Ancestor, len(aa))
+	for _, a := range aa {
+		if a.Tx.TxHash().Equal(&tipID) {
+			continue
+		}
+		ancestors[*a.Tx.TxHash()] = a
+	}
+
+	// visited is false while a tx's parents are being added and true once the tx
+	// itself has been, so that a tx found while it is still being visited is a cycle.
+	visited := make(map[bitcoin.Hash32]bool, len(aa)+1)
+	var add func(a *Ancestor) error
+	add = func(a *Ancestor) error {
+		txid := *a.Tx.TxHash()
+		if done, seen := visited[txid]; seen {
+			if !done {
+				return errors.Wrapf(ErrInvalidBEEF, "tx %s is its own ancestor", txid)
+			}
+			return nil
+		}
+		visited[txid] = false
+
+		btx := &BEEFTx{Tx: a.Tx}
+		if a.IsAnchored() {
+			idx, err := beef.addBUMP(a)
+			if err != nil {
+				return errors.Wrap(err, txid.String())
+			}
+			btx.HasBUMP = true
+			btx.BUMPIndex = idx
+		} else {
+			for _, input := range a.Tx.Inputs {
+				parent, _ := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+				pa, exists := ancestors[*parent]
+				if !exists {
+					continue
+				}
+				if err := add(pa); err != nil {
+					return err
+				}
+			}
+		}
+
+		visited[txid] = true
+		beef.Txs = append(beef.Txs, btx)
+
+		return nil
+	}
+
+	for _, a := range aa {
+		if a.Tx.TxHash().Equal(&tipID) {
+			continue
+		}
+		if err := add(a); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(&Ancestor{Tx: tx}); err != nil {
+		return nil, err
+	}
+
+	return beef, nil
+}
+
+// addBUMP adds the merkle path of a confirmed ancestor to the BUMPs of the BEEF,
+// combining it with the BUMP of the same block if there is one, and returns its index.
+func (b *BEEF) addBUMP(a *Ancestor) (uint64, error) {
+	if a.MerklePath == nil {
+		return 0, ErrMissingMerklePath
+	}
+
+	for i, mp := range b.BUMPs {
+		if mp.BlockHeight != a.MerklePath.BlockHeight {
+			continue
+		}
+		if err := mp.Combine(a.MerklePath); err != nil {
+			return 0, errors.Wrapf(err, "combine with bump %d", i)
+		}
+		return uint64(i), nil
+	}
+
+	// copy the path as it may be combined with others, which modifies it.
+	mpb, err := a.MerklePath.Bytes()
+	if err != nil {
+		return 0, errors.Wrap(err, "merkle path")
+	}
+	mp, err := bc.NewMerklePathFromBytes(mpb)
+	if err != nil {
+		return 0, errors.Wrap(err, "merkle path")
+	}
+	b.BUMPs = append(b.BUMPs, mp)
+
+	return uint64(len(b.BUMPs) - 1), nil
+}
+
+// VerifyBEEF verifies the subject transaction of a binary BEEF in the same way as
+// VerifyPayment, using the other transactions of the BEEF as its ancestry.
+func (v *verifier) VerifyBEEF(ctx context.Context, beef []byte, opts ...VerifyOpt) error {
+	b, err := NewBEEFFromBytes(beef)
+	if err != nil {
+		return err
+	}
+
+	tx, aa, err := b.Ancestors()
+	if err != nil {
+		return err
+	}
+
+	return v.verifyPayment(ctx, tx, aa, opts...)
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,170 @@
+package spv_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bt"
+
+	"github.com/tokenized/go-bc"
+)
+
+const (
+	// testBEEFParent is confirmed and spent by testBEEFPayment.
+	testBEEFParent  = "0200000001e65bf7813ee5bf1793a3487c2701f73b1b47f56fe7f7f36d7ee64b8987563f42000000004847304402206a9fe9ae02ecc95f8a90833707f9d04574618c6b9bb70ec67e5a16c1d04e08090220524fff062a60f03bf6560b392791b73982331f75e72afdd51a0b1d58d9bffe3e41feffffff0240101024010000001976a9144685874765a3e652d6869f5651d19000302275a888ac00e1f505000000001976a914b9be6c0240ce6137722a5ef28121d5967ce1049f88ac67000000"
+	testBEEFPayment = "0200000005c931fb0af1eedc1fcb1c00171c80fcdef48564d3a8582dd530a9ba258497b1ea000000006a473044022011dd49f90eb34195e61712cef41d16ce9496807124b1e1c6205cb06ccfdbef0002203b2553032d166724d89a42a12b997cb1c4a78c4d8bbc72f94ab9ef4c3db36d38412103f6e8ebb2836f89aedbe712fa91eda827df597a01fe1a19fa1658bc1d28d1ad15feffffffc931fb0af1eedc1fcb1c00171c80fcdef48564d3a8582dd530a9ba258497b1ea010000006b483045022100f8a92d8e09a239d863b57c44fd2915558afbe05074c992b72639288f15a5a928022047399d49fcc7354140ecec229fe682383a9e9c59e43da368d35d211a06a17f2641210363d67968518ee1c0485b9b95544c0a9ec8c280b649b72c74fe86c299cd055a3bfeffffff250d555b731c8ba2843781d812f1aefbad643665864f29eef18f0b65b77ca9a7010000006b483045022100ece110a2ae06c67f3d4b25ad4ec2d7acf85fa1618dabef9d7eed5b4e5c50bbea02207716c2a9dd8d1ce64a56a3377eb35892db42dbba62058ebeb1b14ca33a8b98c241210241f2c990d7e0fe5c1c5e4508883b76b9786fcc67ccee1b8724eefa89a8f32981feffffff4713da1eacb49f37bd414205706229ddd8a85639a864c063fd2fd1a943ee1569010000006b483045022100831aac063f1b32f9e5645c5442a6b15cb620c6c49930f0194fa6acf6c864f48202203a8cc93d8bcd6f7d86cac0c7c99485cc1837fc87790f13f5bbf2f3b901e86be341210376c2519a09f7cfbcbe000f823c1e957cadace64296e907ae1ea36536313f0706feffffffcb551ea63f1903d74888a1f8989fb521df1567740a0edcf862fbd4f372783236010000006b483045022100f0fa4ab61952f5497d4dfe6b7a4e5e9b3305a5b94b0d7a6d1536927dfea2aa4202205eaa36045812bf5561c9e3a38fc92e6d89908637a5c0ec250a843f36355418c2412103f6e8ebb2836f89aedbe712fa91eda827df597a01fe1a19fa1658bc1d28d1ad15feffffff02bccdf505000000001976a914349256bff9dbe79285454d0e55d1a3163bd6dff888ac0084d717000000001976a914e2e4d329a79401e0a713210a4c615abdc540eda888ac68000000"
+)
+
+// testBEEFAncestors returns testBEEFPayment and its ancestry of testBEEFParent, proven
+// by a path of a block holding only it.
+func testBEEFAncestors(t *testing.T) (*bt.Tx, spv.Ancestors) {
+	parent, err := bt.NewTxFromString(testBEEFParent)
+	if err != nil {
+		t.Fatalf("Failed to parse parent tx : %s", err)
+	}
+	payment, err := bt.NewTxFromString(testBEEFPayment)
+	if err != nil {
+		t.Fatalf("Failed to parse payment tx : %s", err)
+	}
+
+	txid := bc.Hash(*parent.TxHash())
+	path := &bc.MerklePath{
+		BlockHeight: 103,
+		Path:        [][]bc.MerklePathLeaf{{{Offset: 0, Hash: &txid, TxID: true}}},
+	}
+
+	return payment, spv.Ancestors{{Tx: parent, MerklePath: path}}
+}
+
+func TestBEEF_RoundTrip(t *testing.T) {
+	payment, aa := testBEEFAncestors(t)
+
+	beef, err := spv.NewBEEFFromAncestors(payment, aa)
+	if err != nil {
+		t.Fatalf("Failed to create BEEF : %s", err)
+	}
+	if len(beef.BUMPs) != 1 || len(beef.Txs) != 2 {
+		t.Fatalf("Wrong BEEF size : %d bumps %d txs", len(beef.BUMPs), len(beef.Txs))
+	}
+
+	s := beef.String()
+	if !strings.HasPrefix(s, "0100beef") {
+		t.Fatalf("Wrong BEEF version : %s", s[:8])
+	}
+
+	parsed, err := spv.NewBEEFFromStr(s)
+	if err != nil {
+		t.Fatalf("Failed to parse BEEF : %s", err)
+	}
+	if parsed.String() != s {
+		t.Fatalf("Wrong BEEF after round trip : got %s, want %s", parsed.String(), s)
+	}
+
+	tx, ancestors, err := parsed.Ancestors()
+	if err != nil {
+		t.Fatalf("Failed to get ancestors : %s", err)
+	}
+	if tx.TxID() != payment.TxID() {
+		t.Fatalf("Wrong subject tx : got %s, want %s", tx.TxID(), payment.TxID())
+	}
+	if len(ancestors) != 1 || ancestors[0].MerklePath == nil ||
+		ancestors[0].Tx.TxID() != aa[0].Tx.TxID() {
+		t.Fatalf("Wrong ancestors : %+v", ancestors)
+	}
+}
+
+func TestBEEF_Invalid(t *testing.T) {
+	payment, aa := testBEEFAncestors(t)
+	beef, err := spv.NewBEEFFromAncestors(payment, aa)
+	if err != nil {
+		t.Fatalf("Failed to create BEEF : %s", err)
+	}
+	valid := beef.String()
+
+	tests := map[string]struct {
+		beef   func() string
+		expErr error
+	}{
+		"wrong version": {
+			beef: func() string {
+				return "0200beef" + valid[8:]
+			},
+			expErr: spv.ErrUnsupportedBEEFVersion,
+		},
+		"truncated": {
+			beef: func() string {
+				return valid[:len(valid)-2]
+			},
+			expErr: spv.ErrInvalidBEEF,
+		},
+		"trailing bytes": {
+			beef: func() string {
+				return valid + "00"
+			},
+			expErr: spv.ErrInvalidBEEF,
+		},
+		"bump index out of range": {
+			beef: func() string {
+				b := &spv.BEEF{BUMPs: beef.BUMPs, Txs: []*spv.BEEFTx{
+					{Tx: beef.Txs[0].Tx, HasBUMP: true, BUMPIndex: 1},
+					beef.Txs[1],
+				}}
+				return b.String()
+			},
+			expErr: spv.ErrInvalidBEEF,
+		},
+		"tx not in bump": {
+			beef: func() string {
+				b := &spv.BEEF{BUMPs: beef.BUMPs, Txs: []*spv.BEEFTx{
+					{Tx: beef.Txs[1].Tx, HasBUMP: true},
+				}}
+				return b.String()
+			},
+			expErr: spv.ErrInvalidBEEF,
+		},
+		"child before parent": {
+			beef: func() string {
+				b := &spv.BEEF{BUMPs: beef.BUMPs, Txs: []*spv.BEEFTx{beef.Txs[1], beef.Txs[0]}}
+				return b.String()
+			},
+			expErr: spv.ErrInvalidBEEF,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := spv.NewBEEFFromStr(test.beef())
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestNewBEEFFromAncestors_TxInAncestors(t *testing.T) {
+	payment, aa := testBEEFAncestors(t)
+	aa = append(spv.Ancestors{{Tx: payment}}, aa...)
+
+	beef, err := spv.NewBEEFFromAncestors(payment, aa)
+	if err != nil {
+		t.Fatalf("Failed to create BEEF : %s", err)
+	}
+	if len(beef.Txs) != 2 {
+		t.Fatalf("Wrong tx count : got %d, want 2", len(beef.Txs))
+	}
+	if beef.Tx().TxID() != payment.TxID() {
+		t.Fatalf("Wrong tx : got %s, want %s", beef.Tx().TxID(), payment.TxID())
+	}
+}
+
+func TestNewBEEFFromAncestors_MissingMerklePath(t *testing.T) {
+	payment, aa := testBEEFAncestors(t)
+	aa[0].MerklePath = nil
+	aa[0].Proof = &bc.MerkleProof{TxOrID: aa[0].Tx.TxID()}
+
+	if _, err := spv.NewBEEFFromAncestors(payment, aa); !errors.Is(err, spv.ErrMissingMerklePath) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrMissingMerklePath)
+	}
+}
@@ -0,0 +1,110 @@
+package spv
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+)
+
+// A DoubleSpendError is returned when two txs of a payment, the payment tx or any of its
+// ancestors, spend the same outpoint, or when one of them spends an outpoint twice. It
+// wraps ErrDoubleSpend.
+type DoubleSpendError struct {
+	// Outpoint is the output spent by both txs.
+	Outpoint wire.OutPoint
+	// TxIDs are the txids of the two txs in the order of the ancestors, with the
+	// payment tx after all of them. Both are the same when a tx spends Outpoint twice.
+	TxIDs [2]bitcoin.Hash32
+
+	// input is the index of the input of the second tx which spends Outpoint.
+	input int
+}
+
+func (e *DoubleSpendError) Error() string {
+	return fmt.Sprintf("%s: %s spent by %s and %s", ErrDoubleSpend, e.Outpoint, e.TxIDs[0],
+		e.TxIDs[1])
+}
+
+// Unwrap returns ErrDoubleSpend, so that errors.Is matches it.
+func (e *DoubleSpendError) Unwrap() error {
+	return ErrDoubleSpend
+}
+
+// CheckDoubleSpends returns a *DoubleSpendError if two of the ancestors, or tx and one of
+// the ancestors, spend the same outpoint or if one of them spends an outpoint twice.
+// Copies of the same tx aren't conflicts.
+func (e Ancestors) CheckDoubleSpends(tx *bt.Tx) error {
+	if conflicts := e.doubleSpends(tx, true); len(conflicts) > 0 {
+		return conflicts[0]
+	}
+
+	return nil
+}
+
+// doubleSpends returns the conflicts of the ancestors and tx, stopping at the first if
+// first is set. The ancestors are checked in order followed by tx.
+func (e Ancestors) doubleSpends(tx *bt.Tx, first bool) []*DoubleSpendError {
+	txs := make([]*bt.Tx, 0, len(e)+1)
+	for _, ancestor := range e {
+		txs = append(txs, ancestor.Tx)
+	}
+	txs = append(txs, tx)
+
+	spenders := make(map[wire.OutPoint]bitcoin.Hash32)
+	walked := make(map[bitcoin.Hash32]bool, len(txs))
+	var conflicts []*DoubleSpendError
+	for _, tx := range txs {
+		txid := *tx.TxHash()
+		if walked[txid] {
+			continue // copies of a tx aren't conflicts
+		}
+		walked[txid] = true
+
+		for idx, input := range tx.Inputs {
+			prevID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+			if err != nil {
+				continue // a malformed input can't be a conflict
+			}
+			outpoint := wire.OutPoint{Hash: *prevID, Index: input.PreviousTxOutIndex}
+			if outpoint.Hash.IsZero() && outpoint.Index == math.MaxUint32 {
+				continue // coinbase inputs spend no output
+			}
+
+			spender, spent := spenders[outpoint]
+			if !spent {
+				spenders[outpoint] = txid
+				continue
+			}
+
+			conflicts = append(conflicts, &DoubleSpendError{
+				Outpoint: outpoint,
+				TxIDs:    [2]bitcoin.Hash32{spender, txid},
+				input:    idx,
+			})
+			if first {
+				return conflicts
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// verifyDoubleSpends fails each tx of the payment spending an outpoint already spent by
+// another, on the input spending it.
+func (av *ancestryVerifier) verifyDoubleSpends(paymentTx *bt.Tx) error {
+	for _, conflict := range av.aa.doubleSpends(paymentTx, av.report == nil) {
+		tx := paymentTx
+		if ancestor, err := av.aa.Ancestor(conflict.TxIDs[1]); err == nil {
+			tx = ancestor.Tx
+		}
+		if err := av.fail(av.txReport(tx), conflict.input, conflict); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
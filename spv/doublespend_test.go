@@ -0,0 +1,126 @@
+package spv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bt"
+)
+
+func TestAncestors_CheckDoubleSpends(t *testing.T) {
+	root := testSpend(t, bt.NewTx())
+	a := testSpend(t, root)
+	b := testSpend(t, root)
+	b.Outputs[0].Satoshis-- // so that b isn't a
+	twice := testSpend(t, root, root)
+
+	tests := map[string]struct {
+		ancestors spv.Ancestors
+		tx        *bt.Tx
+		expTxIDs  []*bt.Tx
+	}{
+		"no conflict": {
+			ancestors: spv.Ancestors{{Tx: root}, {Tx: a}},
+			tx:        testSpend(t, a),
+		},
+		"copies of a tx": {
+			ancestors: spv.Ancestors{{Tx: root}, {Tx: a}, {Tx: a}},
+			tx:        testSpend(t, a),
+		},
+		"ancestors conflict": {
+			ancestors: spv.Ancestors{{Tx: root}, {Tx: a}, {Tx: b}},
+			tx:        testSpend(t, a, b),
+			expTxIDs:  []*bt.Tx{a, b},
+		},
+		"tx spends an outpoint twice": {
+			ancestors: spv.Ancestors{{Tx: root}},
+			tx:        twice,
+			expTxIDs:  []*bt.Tx{twice, twice},
+		},
+		"ancestor spends an outpoint twice": {
+			ancestors: spv.Ancestors{{Tx: root}, {Tx: twice}, {Tx: twice}},
+			tx:        testSpend(t, twice),
+			expTxIDs:  []*bt.Tx{twice, twice},
+		},
+		"payment tx conflicts with ancestor": {
+			ancestors: spv.Ancestors{{Tx: root}, {Tx: a}},
+			tx:        b,
+			expTxIDs:  []*bt.Tx{a, b},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.ancestors.CheckDoubleSpends(test.tx)
+			if test.expTxIDs == nil {
+				if err != nil {
+					t.Fatalf("Failed to check double spends : %s", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, spv.ErrDoubleSpend) {
+				t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrDoubleSpend)
+			}
+			var dsErr *spv.DoubleSpendError
+			if !errors.As(err, &dsErr) {
+				t.Fatalf("Error should be a DoubleSpendError : %v", err)
+			}
+			if !dsErr.Outpoint.Hash.Equal(root.TxHash()) || dsErr.Outpoint.Index != 0 {
+				t.Fatalf("Wrong outpoint : got %s, want %s:0", dsErr.Outpoint, root.TxID())
+			}
+			for i, tx := range test.expTxIDs {
+				if !dsErr.TxIDs[i].Equal(tx.TxHash()) {
+					t.Fatalf("Wrong txid %d : got %s, want %s", i, dsErr.TxIDs[i], tx.TxID())
+				}
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPaymentReport_DoubleSpend(t *testing.T) {
+	root := testSpend(t, bt.NewTx())
+	a := testSpend(t, root)
+	b := testSpend(t, root)
+	b.Outputs[0].Satoshis--
+	tip := testSpend(t, a, b)
+
+	ancestors := spv.Ancestors{
+		{Tx: root, Proof: testProof(t, root)},
+		{Tx: a},
+		{Tx: b},
+	}
+	ab, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	payment := &spv.Payment{PaymentTx: tip, Ancestors: ab}
+
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+	if err := verifier.VerifyPayment(context.Background(), payment); !errors.Is(err,
+		spv.ErrDoubleSpend) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrDoubleSpend)
+	}
+
+	report, err := verifier.VerifyPaymentReport(context.Background(), payment)
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	failures := report.Failures()
+	if report.Valid || len(failures) != 1 {
+		t.Fatalf("Payment should have one failure : %v", failures)
+	}
+	if f := failures[0]; f.Code != spv.FailureDoubleSpend || f.Input == nil || *f.Input != 0 {
+		t.Fatalf("Wrong failure : %+v", f)
+	}
+	for _, txr := range report.Txs {
+		if len(txr.Failures) > 0 && txr.TxID != b.TxID() {
+			t.Fatalf("Wrong tx failed : got %s, want %s", txr.TxID, b.TxID())
+		}
+	}
+}
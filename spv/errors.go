@@ -67,4 +67,41 @@ var (
 
 	// ErrInvalidNodes returns if there is a * on the left hand side within the node array.
 	ErrInvalidNodes = errors.New("invalid nodes")
+
+	// ErrNoHeaderAtHeight returns if a merkle path is verified with a bc.BlockHeaderChain that
+	// can't look up headers by height.
+	ErrNoHeaderAtHeight = errors.New("block header chain can't return headers by height, required for merkle paths")
+
+	// ErrInvalidBEEF returns if a BEEF transaction envelope is malformed.
+	ErrInvalidBEEF = errors.New("invalid BEEF")
+
+	// ErrUnsupportedBEEFVersion returns if a BEEF isn't of the supported version.
+	ErrUnsupportedBEEFVersion = errors.New("unsupported BEEF version")
+
+	// ErrMissingMerklePath returns if a BEEF is created from a confirmed ancestor without a merkle path.
+	ErrMissingMerklePath = errors.New("confirmed ancestor has no merkle path, required for BEEF")
+
+	// ErrNotExtendedFormat returns if a tx is parsed or encoded in Extended Format without being in it.
+	ErrNotExtendedFormat = errors.New("tx is not in extended format")
+
+	// ErrInvalidExtendedTx returns if a tx in Extended Format is malformed.
+	ErrInvalidExtendedTx = errors.New("invalid extended format tx")
+
+	// ErrInvalidScript returns if an unlocking script fails to satisfy the locking script of the output it spends.
+	ErrInvalidScript = errors.New("unlocking script failed to satisfy locking script")
+
+	// ErrAncestryTooDeep returns if populating an ancestry needs more generations than allowed.
+	ErrAncestryTooDeep = errors.New("ancestry is too deep")
+
+	// ErrAncestryTooManyTxs returns if populating an ancestry needs more txs than allowed.
+	ErrAncestryTooManyTxs = errors.New("ancestry has too many txs")
+
+	// ErrAncestryTooLarge returns if populating an ancestry needs more bytes than allowed.
+	ErrAncestryTooLarge = errors.New("ancestry is too large")
+
+	// ErrDoubleSpend returns if two txs of a payment spend the same output, wrapped by a DoubleSpendError.
+	ErrDoubleSpend = errors.New("output is spent twice in the payment")
+
+	// ErrNoBlockHeaderChain returns if proofs are verified by a verifier without a block header chain.
+	ErrNoBlockHeaderChain = errors.New("a block header chain is required to verify proofs")
 )
@@ -0,0 +1,164 @@
+package spv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/go-bt/bscript"
+)
+
+// extendedMarker follows the version of a tx in Extended Format, where a standard tx
+// would have its input count. As a tx can't have zero inputs it can't be mistaken for
+// a standard tx.
+var extendedMarker = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0xEF}
+
+// NewExtendedTxFromStr returns the tx of the hex string of its Extended Format encoding.
+func NewExtendedTxFromStr(s string) (*bt.Tx, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewExtendedTxFromBytes(b)
+}
+
+// NewExtendedTxFromBytes parses a tx in Extended Format (BRC-30), where each input is
+// followed by the satoshis and locking script of the output it spends. These are set
+// as the PreviousTxSatoshis and PreviousTxScript of the inputs of the returned tx, so
+// its fees and scripts can be checked without its parents.
+//
+// ErrNotExtendedFormat is returned if b doesn't have the Extended Format marker and
+// ErrInvalidExtendedTx if it is otherwise malformed.
+//
+// See https://github.com/bitcoin-sv/BRCs/blob/master/transactions/0030.md
+func NewExtendedTxFromBytes(b []byte) (*bt.Tx, error) {
+	r := bytes.NewReader(b)
+
+	tx := bt.NewTx()
+	if err := binary.Read(r, binary.LittleEndian, &tx.Version); err != nil {
+		return nil, errors.Wrap(ErrInvalidExtendedTx, "reading version")
+	}
+
+	marker := make([]byte, len(extendedMarker))
+	if _, err := io.ReadFull(r, marker); err != nil || !bytes.Equal(marker, extendedMarker) {
+		return nil, ErrNotExtendedFormat
+	}
+
+	var nInputs bt.VarInt
+	if _, err := nInputs.ReadFrom(r); err != nil {
+		return nil, errors.Wrapf(ErrInvalidExtendedTx, "reading input count: %s", err)
+	}
+	tx.Inputs = make([]*bt.Input, 0, minUint64(uint64(nInputs), 1<<10))
+	for i := uint64(0); i < uint64(nInputs); i++ {
+		input, err := readExtendedInput(r)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidExtendedTx, "reading input %d: %s", i, err)
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+
+	var nOutputs bt.VarInt
+	if _, err := nOutputs.ReadFrom(r); err != nil {
+		return nil, errors.Wrapf(ErrInvalidExtendedTx, "reading output count: %s", err)
+	}
+	tx.Outputs = make([]*bt.Output, 0, minUint64(uint64(nOutputs), 1<<10))
+	for i := uint64(0); i < uint64(nOutputs); i++ {
+		output := &bt.Output{}
+		if _, err := output.ReadFrom(r); err != nil {
+			return nil, errors.Wrapf(ErrInvalidExtendedTx, "reading output %d: %s", i, err)
+		}
+		tx.Outputs = append(tx.Outputs, output)
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &tx.LockTime); err != nil {
+		return nil, errors.Wrap(ErrInvalidExtendedTx, "reading lock time")
+	}
+
+	if r.Len() > 0 {
+		return nil, errors.Wrapf(ErrInvalidExtendedTx, "%d unexpected bytes after lock time", r.Len())
+	}
+
+	return tx, nil
+}
+
+// readExtendedInput reads an input along with the satoshis and locking script of the
+// output it spends.
+func readExtendedInput(r io.Reader) (*bt.Input, error) {
+	input := &bt.Input{}
+	if _, err := input.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "input")
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &input.PreviousTxSatoshis); err != nil {
+		return nil, errors.Wrap(err, "previous satoshis")
+	}
+
+	var l bt.VarInt
+	if _, err := l.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "previous locking script length")
+	}
+	// the length is untrusted so the script is read through a limited reader rather
+	// than allocated up front.
+	var script bytes.Buffer
+	if n, err := io.Copy(&script, io.LimitReader(r, int64(l))); err != nil || uint64(n) != uint64(l) {
+		return nil, errors.Wrapf(io.ErrUnexpectedEOF, "previous locking script(%d): got %d bytes",
+			l, script.Len())
+	}
+	input.PreviousTxScript = bscript.NewFromBytes(script.Bytes())
+
+	return input, nil
+}
+
+// ExtendedTxBytes returns the Extended Format encoding of tx, which requires the
+// PreviousTxScript and PreviousTxSatoshis of each of its inputs to be set.
+// ErrNotExtendedFormat is returned if an input has no PreviousTxScript.
+func ExtendedTxBytes(tx *bt.Tx) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, tx.Version); err != nil {
+		return nil, errors.Wrap(err, "version")
+	}
+	buf.Write(extendedMarker)
+
+	buf.Write(bt.VarInt(uint64(len(tx.Inputs))).Bytes())
+	for i, input := range tx.Inputs {
+		if input.PreviousTxScript == nil {
+			return nil, errors.Wrapf(ErrNotExtendedFormat, "input %d has no previous locking script", i)
+		}
+		buf.Write(input.Bytes(false))
+		if err := binary.Write(&buf, binary.LittleEndian, input.PreviousTxSatoshis); err != nil {
+			return nil, errors.Wrapf(err, "input %d previous satoshis", i)
+		}
+		buf.Write(bt.VarInt(uint64(len(*input.PreviousTxScript))).Bytes())
+		buf.Write(*input.PreviousTxScript)
+	}
+
+	buf.Write(bt.VarInt(uint64(len(tx.Outputs))).Bytes())
+	for _, output := range tx.Outputs {
+		buf.Write(output.Bytes())
+	}
+
+	if err := binary.Write(&buf, binary.LittleEndian, tx.LockTime); err != nil {
+		return nil, errors.Wrap(err, "lock time")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// IsExtended returns true if every input of tx has the previous satoshis and locking
+// script of Extended Format set, so it can be verified without its parents.
+func IsExtended(tx *bt.Tx) bool {
+	if len(tx.Inputs) == 0 {
+		return false
+	}
+	for _, input := range tx.Inputs {
+		if input.PreviousTxScript == nil {
+			return false
+		}
+	}
+
+	return true
+}
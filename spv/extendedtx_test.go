@@ -0,0 +1,109 @@
+package spv_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bt"
+)
+
+// testExtendedTx returns testBEEFPayment with the outputs its inputs spend set, the
+// one spending testBEEFParent from it and the others made up.
+func testExtendedTx(t *testing.T) *bt.Tx {
+	payment, aa := testBEEFAncestors(t)
+	for i, input := range payment.Inputs {
+		out := aa[0].Tx.Outputs[0]
+		if input.PreviousTxIDStr() == aa[0].Tx.TxID() {
+			out = aa[0].Tx.Outputs[input.PreviousTxOutIndex]
+		}
+		input.PreviousTxSatoshis = out.Satoshis + uint64(i)
+		input.PreviousTxScript = out.LockingScript
+	}
+
+	return payment
+}
+
+func TestExtendedTx_RoundTrip(t *testing.T) {
+	tx := testExtendedTx(t)
+	if !spv.IsExtended(tx) {
+		t.Fatalf("Tx should be extended")
+	}
+
+	b, err := spv.ExtendedTxBytes(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode extended tx : %s", err)
+	}
+	if hex.EncodeToString(b[4:10]) != "0000000000ef" {
+		t.Fatalf("Missing extended format marker : %x", b[4:10])
+	}
+
+	parsed, err := spv.NewExtendedTxFromBytes(b)
+	if err != nil {
+		t.Fatalf("Failed to parse extended tx : %s", err)
+	}
+	if parsed.TxID() != tx.TxID() {
+		t.Fatalf("Wrong txid : got %s, want %s", parsed.TxID(), tx.TxID())
+	}
+	for i, input := range parsed.Inputs {
+		if input.PreviousTxSatoshis != tx.Inputs[i].PreviousTxSatoshis {
+			t.Fatalf("Wrong previous satoshis for input %d : got %d, want %d", i,
+				input.PreviousTxSatoshis, tx.Inputs[i].PreviousTxSatoshis)
+		}
+		if input.PreviousTxScript.String() != tx.Inputs[i].PreviousTxScript.String() {
+			t.Fatalf("Wrong previous locking script for input %d", i)
+		}
+	}
+
+	rb, err := spv.ExtendedTxBytes(parsed)
+	if err != nil {
+		t.Fatalf("Failed to encode parsed extended tx : %s", err)
+	}
+	if hex.EncodeToString(rb) != hex.EncodeToString(b) {
+		t.Fatalf("Wrong extended tx after round trip")
+	}
+}
+
+func TestExtendedTx_Invalid(t *testing.T) {
+	tx := testExtendedTx(t)
+	b, err := spv.ExtendedTxBytes(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode extended tx : %s", err)
+	}
+
+	tests := map[string]struct {
+		b      []byte
+		expErr error
+	}{
+		"standard tx": {
+			b:      tx.Bytes(),
+			expErr: spv.ErrNotExtendedFormat,
+		},
+		"truncated": {
+			b:      b[:len(b)-1],
+			expErr: spv.ErrInvalidExtendedTx,
+		},
+		"trailing bytes": {
+			b:      append(append([]byte{}, b...), 0x00),
+			expErr: spv.ErrInvalidExtendedTx,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := spv.NewExtendedTxFromBytes(test.b)
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+
+	tx.Inputs[0].PreviousTxScript = nil
+	if spv.IsExtended(tx) {
+		t.Fatalf("Tx should not be extended")
+	}
+	if _, err := spv.ExtendedTxBytes(tx); !errors.Is(err, spv.ErrNotExtendedFormat) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrNotExtendedFormat)
+	}
+}
@@ -0,0 +1,105 @@
+package spv
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/pkg/json_envelope"
+
+	"github.com/tokenized/go-bc"
+)
+
+// FlatAncestor is an ancestor in the flat JSON format of the TSC SPV envelope, where
+// the ancestry is a list of txs rather than nested by parent.
+type FlatAncestor struct {
+	RawTx         string                       `json:"rawtx"`
+	Proof         *bc.MerkleProof              `json:"proof,omitempty"`
+	MapiResponses []json_envelope.JSONEnvelope `json:"mapiResponses,omitempty"`
+}
+
+// MarshalFlatJSON returns the ancestors in the flat JSON format of the TSC, a list of
+// FlatAncestor in the order of the ancestors.
+//
+// As the format has no merkle paths, an ancestor anchored by a MerklePath alone is
+// given the merkle proof of its tx read from the path, targeting the merkle root.
+func (e Ancestors) MarshalFlatJSON() ([]byte, error) {
+	flat := make([]*FlatAncestor, 0, len(e))
+	for i, ancestor := range e {
+		fa, err := ancestor.flat()
+		if err != nil {
+			return nil, errors.Wrapf(err, "ancestor %d", i)
+		}
+		flat = append(flat, fa)
+	}
+
+	return json.Marshal(flat)
+}
+
+// UnmarshalFlatJSON replaces the ancestors with those of b in the flat JSON format of
+// the TSC. Both a list of FlatAncestor and a map of them keyed by txid are accepted,
+// with the txid keys checked against the txs. The txs of a map are ordered by txid as
+// JSON objects are unordered.
+func (e *Ancestors) UnmarshalFlatJSON(b []byte) error {
+	var flat []*FlatAncestor
+	// txids holds the keys of a map, in the order of flat.
+	var txids []string
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		var keyed map[string]*FlatAncestor
+		if err := json.Unmarshal(b, &keyed); err != nil {
+			return errors.Wrap(err, "unmarshal")
+		}
+		for txid := range keyed {
+			txids = append(txids, txid)
+		}
+		sort.Strings(txids)
+		for _, txid := range txids {
+			flat = append(flat, keyed[txid])
+		}
+	} else if err := json.Unmarshal(b, &flat); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	ancestors := make(Ancestors, 0, len(flat))
+	for i, fa := range flat {
+		if fa == nil {
+			return errors.Errorf("ancestor %d is null", i)
+		}
+		tx, err := bt.NewTxFromString(fa.RawTx)
+		if err != nil {
+			return errors.Wrapf(err, "ancestor %d", i)
+		}
+		if txids != nil && tx.TxID() != txids[i] {
+			return errors.Wrapf(ErrTxIDMismatch, "ancestor %s has tx %s", txids[i], tx.TxID())
+		}
+		ancestors = append(ancestors, &Ancestor{
+			Tx:            tx,
+			Proof:         fa.Proof,
+			MapiResponses: fa.MapiResponses,
+		})
+	}
+	*e = ancestors
+
+	return nil
+}
+
+// flat returns the ancestor in the flat JSON format of the TSC.
+func (e *Ancestor) flat() (*FlatAncestor, error) {
+	fa := &FlatAncestor{
+		RawTx:         e.Tx.String(),
+		Proof:         e.Proof,
+		MapiResponses: e.MapiResponses,
+	}
+
+	if fa.Proof == nil && e.MerklePath != nil {
+		proof, err := e.MerklePath.MerkleProof(bc.Hash(*e.Tx.TxHash()))
+		if err != nil {
+			return nil, errors.Wrap(err, "merkle path")
+		}
+		fa.Proof = proof
+	}
+
+	return fa, nil
+}
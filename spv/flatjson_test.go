@@ -0,0 +1,125 @@
+package spv_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bc/testing/data"
+)
+
+func TestAncestors_FlatJSON_RoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		file     string
+		expCount int
+	}{
+		"3 serial": {
+			file:     "3_serial.json",
+			expCount: 4,
+		},
+		"1000 serial": {
+			file:     "1000_serial.json",
+			expCount: 1001,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := data.SpvSerialJSONData.Load(test.file)
+			if err != nil {
+				t.Fatalf("Failed to load data : %s", err)
+			}
+
+			var ancestors spv.Ancestors
+			if err := ancestors.UnmarshalFlatJSON(b); err != nil {
+				t.Fatalf("Failed to unmarshal ancestors : %s", err)
+			}
+			if len(ancestors) != test.expCount {
+				t.Fatalf("Wrong ancestor count : got %d, want %d", len(ancestors), test.expCount)
+			}
+
+			js, err := ancestors.MarshalFlatJSON()
+			if err != nil {
+				t.Fatalf("Failed to marshal ancestors : %s", err)
+			}
+
+			var want, got interface{}
+			if err := json.Unmarshal(b, &want); err != nil {
+				t.Fatalf("Failed to unmarshal data : %s", err)
+			}
+			if err := json.Unmarshal(js, &got); err != nil {
+				t.Fatalf("Failed to unmarshal marshalled ancestors : %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Wrong ancestors after round trip : got %s", js)
+			}
+		})
+	}
+}
+
+func TestAncestors_UnmarshalFlatJSON_Keyed(t *testing.T) {
+	b, err := data.SpvSerialJSONData.Load("3_serial.json")
+	if err != nil {
+		t.Fatalf("Failed to load data : %s", err)
+	}
+	var ancestors spv.Ancestors
+	if err := ancestors.UnmarshalFlatJSON(b); err != nil {
+		t.Fatalf("Failed to unmarshal ancestors : %s", err)
+	}
+
+	var list []*spv.FlatAncestor
+	if err := json.Unmarshal(b, &list); err != nil {
+		t.Fatalf("Failed to unmarshal data : %s", err)
+	}
+	keyed := make(map[string]*spv.FlatAncestor)
+	for i, fa := range list {
+		keyed[ancestors[i].Tx.TxID()] = fa
+	}
+	kb, err := json.Marshal(keyed)
+	if err != nil {
+		t.Fatalf("Failed to marshal keyed ancestors : %s", err)
+	}
+
+	var fromKeyed spv.Ancestors
+	if err := fromKeyed.UnmarshalFlatJSON(kb); err != nil {
+		t.Fatalf("Failed to unmarshal keyed ancestors : %s", err)
+	}
+	if len(fromKeyed) != len(ancestors) {
+		t.Fatalf("Wrong ancestor count : got %d, want %d", len(fromKeyed), len(ancestors))
+	}
+	for _, a := range ancestors {
+		if _, err := fromKeyed.Ancestor(*a.Tx.TxHash()); err != nil {
+			t.Fatalf("Missing ancestor %s : %s", a.Tx.TxID(), err)
+		}
+	}
+
+	// a key which isn't the txid of its tx is rejected.
+	wrong := map[string]*spv.FlatAncestor{ancestors[0].Tx.TxID(): list[1]}
+	wb, err := json.Marshal(wrong)
+	if err != nil {
+		t.Fatalf("Failed to marshal keyed ancestors : %s", err)
+	}
+	if err := fromKeyed.UnmarshalFlatJSON(wb); !errors.Is(err, spv.ErrTxIDMismatch) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrTxIDMismatch)
+	}
+}
+
+func TestAncestors_MarshalFlatJSON_MerklePath(t *testing.T) {
+	_, ancestors := testBEEFAncestors(t)
+
+	js, err := ancestors.MarshalFlatJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+
+	var flat spv.Ancestors
+	if err := flat.UnmarshalFlatJSON(js); err != nil {
+		t.Fatalf("Failed to unmarshal ancestors : %s", err)
+	}
+	proof := flat[0].Proof
+	if proof == nil || proof.TargetType != "merkleRoot" || proof.TxOrID != ancestors[0].Tx.TxID() {
+		t.Fatalf("Wrong proof from merkle path : %+v", proof)
+	}
+}
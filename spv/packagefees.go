@@ -0,0 +1,215 @@
+package spv
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+// A packageTx is an unanchored tx of a payment, the payment tx or one of its unconfirmed
+// ancestors, as evaluated for package fees.
+type packageTx struct {
+	tx  *bt.Tx
+	txr *TxReport
+	// parents holds the indices of the unanchored parents of the tx in its package.
+	parents []int
+	// priced is false when the fee of the tx isn't known, as the satoshis of one of its
+	// inputs are missing or it spends more than its inputs.
+	priced bool
+	fee    uint64
+	size   *bt.TxSize
+	mined  bool
+}
+
+// A txPackage holds the unanchored txs of a payment, starting with the payment tx and
+// followed by its unconfirmed ancestors in the order they are reached.
+type txPackage []*packageTx
+
+// verifyPackageFees checks the payment tx and every unconfirmed tx in its ancestry are
+// paid for under the package (child pays for parent) rules.
+//
+// The txs are mined as a miner would: each round the unmined tx whose package, being it
+// and its unmined unconfirmed ancestors, has the highest fee rate and pays enough for its
+// combined size is mined along with its package. The txs left once no package pays
+// enough fail with ErrFeePaidNotEnough, other than those with ancestors whose fees
+// aren't known, which are left incomplete.
+func (av *ancestryVerifier) verifyPackageFees(tx *bt.Tx, txr *TxReport) error {
+	stdFee, err := av.o.feeQuote.Fee(bt.FeeTypeStandard)
+	if err != nil {
+		return errors.Wrap(err, "standard fee")
+	}
+	dataFee, err := av.o.feeQuote.Fee(bt.FeeTypeData)
+	if err != nil {
+		return errors.Wrap(err, "data fee")
+	}
+	if stdFee.MiningFee.Bytes <= 0 || dataFee.MiningFee.Bytes <= 0 {
+		return errors.New("fee quote has a mining fee of zero bytes")
+	}
+	requiredFee := func(size *bt.TxSize) uint64 {
+		return size.TotalStdBytes*uint64(stdFee.MiningFee.Satoshis)/uint64(stdFee.MiningFee.Bytes) +
+			size.TotalDataBytes*uint64(dataFee.MiningFee.Satoshis)/uint64(dataFee.MiningFee.Bytes)
+	}
+
+	var p txPackage
+	if _, err := av.addPackageTx(&p, make(map[bitcoin.Hash32]int), tx, txr); err != nil {
+		return err
+	}
+
+	for {
+		var best []int
+		var bestRate float64
+		for i, ptx := range p {
+			if ptx.mined {
+				continue
+			}
+			txs := p.unminedAncestors(i)
+			fee, size, priced := p.totals(txs)
+			if !priced || fee < requiredFee(size) {
+				continue
+			}
+			if rate := feeRate(fee, size.TotalBytes); best == nil || rate > bestRate {
+				best, bestRate = txs, rate
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		for _, i := range best {
+			rate := bestRate
+			p[i].mined = true
+			p[i].txr.EffectiveFeeRate = &rate
+			p[i].txr.Fees = CheckPassed
+		}
+	}
+
+	for i, ptx := range p {
+		if ptx.mined || !ptx.priced {
+			continue
+		}
+
+		fee, size, priced := p.totals(p.unminedAncestors(i))
+		if !priced {
+			ptx.txr.Fees = CheckIncomplete
+			continue
+		}
+		rate := feeRate(fee, size.TotalBytes)
+		ptx.txr.EffectiveFeeRate = &rate
+		ptx.txr.Fees = CheckFailed
+		if err := av.fail(ptx.txr, -1, errors.Wrapf(ErrFeePaidNotEnough,
+			"tx %s pays %d of %d satoshis with its unconfirmed ancestors", ptx.tx.TxID(), fee,
+			requiredFee(size))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPackageTx adds tx and its unanchored ancestors to the package, returning the index
+// of tx. index holds the index of each tx already added.
+func (av *ancestryVerifier) addPackageTx(p *txPackage, index map[bitcoin.Hash32]int,
+	tx *bt.Tx, txr *TxReport) (int, error) {
+
+	ptx := &packageTx{
+		tx:     tx,
+		txr:    txr,
+		priced: true,
+		size:   tx.SizeWithTypes(),
+	}
+	i := len(*p)
+	*p = append(*p, ptx)
+	index[*tx.TxHash()] = i
+
+	var in uint64
+	for idx, input := range tx.Inputs {
+		satoshis, _, err := av.aa.previousOutput(input)
+		if err != nil {
+			ptx.priced = false
+			txr.Fees = CheckFailed
+			if err := av.fail(txr, idx, errors.Wrapf(err, "input %d of tx %s", idx,
+				tx.TxID())); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		in += satoshis
+
+		inputID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+		if err != nil {
+			return 0, errors.Wrapf(err, "input %d of tx %s", idx, tx.TxID())
+		}
+		parent, err := av.aa.Ancestor(*inputID)
+		if err != nil || parent.IsAnchored() {
+			continue
+		}
+
+		pi, added := index[*inputID]
+		if !added {
+			if pi, err = av.addPackageTx(p, index, parent.Tx, av.txReport(parent.Tx)); err != nil {
+				return 0, err
+			}
+		}
+		ptx.parents = append(ptx.parents, pi)
+	}
+
+	if !ptx.priced {
+		return i, nil
+	}
+	out := tx.TotalOutputSatoshis()
+	if in < out {
+		ptx.priced = false
+		txr.Fees = CheckFailed
+		return i, av.fail(txr, -1, errors.Wrapf(ErrFeePaidNotEnough,
+			"tx %s spends %d satoshis more than its inputs", tx.TxID(), out-in))
+	}
+	ptx.fee = in - out
+	rate := feeRate(ptx.fee, ptx.size.TotalBytes)
+	txr.FeeRate = &rate
+
+	return i, nil
+}
+
+// unminedAncestors returns the indices of tx i and its unmined ancestors in the package.
+func (p txPackage) unminedAncestors(i int) []int {
+	seen := map[int]bool{i: true}
+	txs := []int{i}
+	for j := 0; j < len(txs); j++ {
+		for _, pi := range p[txs[j]].parents {
+			if seen[pi] || p[pi].mined {
+				continue
+			}
+			seen[pi] = true
+			txs = append(txs, pi)
+		}
+	}
+
+	return txs
+}
+
+// totals returns the combined fee and size of the txs of the package, with priced false
+// if the fee of one of them isn't known.
+func (p txPackage) totals(txs []int) (uint64, *bt.TxSize, bool) {
+	var fee uint64
+	size := &bt.TxSize{}
+	for _, i := range txs {
+		if !p[i].priced {
+			return 0, nil, false
+		}
+		fee += p[i].fee
+		size.TotalBytes += p[i].size.TotalBytes
+		size.TotalStdBytes += p[i].size.TotalStdBytes
+		size.TotalDataBytes += p[i].size.TotalDataBytes
+	}
+
+	return fee, size, true
+}
+
+// feeRate returns the rate of fee over size in satoshis per byte.
+func feeRate(fee, size uint64) float64 {
+	if size == 0 {
+		return 0
+	}
+
+	return float64(fee) / float64(size)
+}
@@ -0,0 +1,138 @@
+package spv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bt"
+)
+
+// testFeeQuote returns a fee quote of 1 satoshi per byte.
+func testFeeQuote() *bt.FeeQuote {
+	fees := bt.NewFeeQuote()
+	for _, ft := range []bt.FeeType{bt.FeeTypeStandard, bt.FeeTypeData} {
+		fees.AddQuote(ft, &bt.Fee{
+			FeeType:   ft,
+			MiningFee: bt.FeeUnit{Satoshis: 1, Bytes: 1},
+			RelayFee:  bt.FeeUnit{Satoshis: 1, Bytes: 1},
+		})
+	}
+
+	return fees
+}
+
+// testPackage returns a payment tx spending an unconfirmed parent, which spends an
+// anchored tx of 1000 satoshis, with the parent and payment tx paying the fees given.
+func testPackage(t *testing.T, parentFee, tipFee uint64) (*spv.Payment, *bt.Tx) {
+	root := testSpend(t, bt.NewTx())
+	parent := testSpend(t, root)
+	parent.Outputs[0].Satoshis -= parentFee
+	tip := testSpend(t, parent)
+	tip.Outputs[0].Satoshis = parent.Outputs[0].Satoshis - tipFee
+
+	ancestors := spv.Ancestors{
+		{Tx: root, Proof: testProof(t, root)},
+		{Tx: parent},
+	}
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+
+	return &spv.Payment{PaymentTx: tip, Ancestors: b}, parent
+}
+
+func TestVerifier_VerifyPayment_PackageFees(t *testing.T) {
+	tests := map[string]struct {
+		parentFee, tipFee uint64
+		expErr            error
+	}{
+		"both pay": {
+			parentFee: 100,
+			tipFee:    100,
+		},
+		"child pays for parent": {
+			tipFee: 200,
+		},
+		"child doesn't pay for parent": {
+			tipFee: 100,
+			expErr: spv.ErrFeePaidNotEnough,
+		},
+		"parent can't pay for child": {
+			parentFee: 200,
+			expErr:    spv.ErrFeePaidNotEnough,
+		},
+	}
+
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			payment, _ := testPackage(t, test.parentFee, test.tipFee)
+
+			err := verifier.VerifyPayment(context.Background(), payment,
+				spv.VerifyPackageFees(testFeeQuote()))
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("Failed to verify payment : %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPaymentReport_PackageFees(t *testing.T) {
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+
+	// the payment tx pays enough alone, so only the package check sees the parent.
+	payment, _ := testPackage(t, 0, 100)
+	if err := verifier.VerifyPayment(context.Background(), payment,
+		spv.VerifyFees(testFeeQuote())); err != nil {
+		t.Fatalf("Failed to verify payment fees : %s", err)
+	}
+	report, err := verifier.VerifyPaymentReport(context.Background(), payment,
+		spv.VerifyPackageFees(testFeeQuote()))
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if report.Valid || len(report.Failures()) != 2 {
+		t.Fatalf("Both txs should fail : %v", report.Failures())
+	}
+
+	payment, parent := testPackage(t, 0, 200)
+	report, err = verifier.VerifyPaymentReport(context.Background(), payment,
+		spv.VerifyPackageFees(testFeeQuote()))
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if !report.Valid || len(report.Txs) != 2 {
+		t.Fatalf("Payment should be valid with 2 txs : %v", report.Err())
+	}
+
+	size := len(payment.PaymentTx.Bytes()) + len(parent.Bytes())
+	expRate := float64(200) / float64(size)
+	for _, txr := range report.Txs {
+		if txr.Fees != spv.CheckPassed || txr.FeeRate == nil || txr.EffectiveFeeRate == nil {
+			t.Fatalf("Tx %s should have passed fees : %+v", txr.TxID, txr)
+		}
+		if *txr.EffectiveFeeRate != expRate {
+			t.Fatalf("Wrong effective fee rate of %s : got %f, want %f", txr.TxID,
+				*txr.EffectiveFeeRate, expRate)
+		}
+	}
+	if txr := report.Txs[1]; txr.TxID != parent.TxID() || *txr.FeeRate != 0 {
+		t.Fatalf("Parent should pay no fee : %+v", txr)
+	}
+}
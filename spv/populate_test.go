@@ -0,0 +1,302 @@
+package spv_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/go-bt/bscript"
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/tokenized/go-bc"
+)
+
+// testSpend returns a tx spending output 0 of each of parents.
+func testSpend(t *testing.T, parents ...*bt.Tx) *bt.Tx {
+	tx := bt.NewTx()
+	for _, parent := range parents {
+		input := &bt.Input{
+			UnlockingScript: bscript.NewFromBytes([]byte{bscript.OpTRUE}),
+			SequenceNumber:  bt.DefaultSequenceNumber,
+		}
+		if err := input.PreviousTxIDAdd(parent.TxIDBytes()); err != nil {
+			t.Fatalf("Failed to add previous txid : %s", err)
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+	tx.Outputs = append(tx.Outputs, &bt.Output{
+		Satoshis:      1000,
+		LockingScript: bscript.NewFromBytes([]byte{bscript.OpTRUE}),
+	})
+
+	return tx
+}
+
+// testProof returns a merkle proof of tx as the first of a block of two txs, the second
+// being a copy of it, targeting the merkle root of the block.
+func testProof(t *testing.T, tx *bt.Tx) *bc.MerkleProof {
+	root, err := bc.MerkleTreeParentStr(tx.TxID(), tx.TxID())
+	if err != nil {
+		t.Fatalf("Failed to calculate merkle root : %s", err)
+	}
+
+	return &bc.MerkleProof{
+		Index:      0,
+		TxOrID:     tx.TxID(),
+		Target:     root,
+		TargetType: "merkleRoot",
+		Nodes:      []string{"*"},
+	}
+}
+
+// testStore is a TxStore and MerkleProofStore of txs, the anchored of which have an
+// empty merkle proof, counting the txs fetched.
+type testStore struct {
+	txs      map[bitcoin.Hash32]*bt.Tx
+	anchored map[bitcoin.Hash32]bool
+	fetches  int32
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		txs:      make(map[bitcoin.Hash32]*bt.Tx),
+		anchored: make(map[bitcoin.Hash32]bool),
+	}
+}
+
+func (s *testStore) add(tx *bt.Tx, anchored bool) {
+	s.txs[*tx.TxHash()] = tx
+	s.anchored[*tx.TxHash()] = anchored
+}
+
+func (s *testStore) Tx(ctx context.Context, txID bitcoin.Hash32) (*bt.Tx, error) {
+	atomic.AddInt32(&s.fetches, 1)
+	return s.txs[txID], nil
+}
+
+func (s *testStore) MerkleProof(ctx context.Context, txID bitcoin.Hash32) (*bc.MerkleProof, error) {
+	if !s.anchored[txID] {
+		return nil, nil
+	}
+
+	return &bc.MerkleProof{TxOrID: txID.String()}, nil
+}
+
+// testChain returns a store of an anchored tx followed by length unconfirmed txs, each
+// spending the last, and a tip spending the last of them.
+func testChain(t *testing.T, length int) (*testStore, *bt.Tx) {
+	store := newTestStore()
+	tx := testSpend(t, bt.NewTx())
+	store.add(tx, true)
+	for i := 0; i < length; i++ {
+		tx = testSpend(t, tx)
+		store.add(tx, false)
+	}
+
+	return store, testSpend(t, tx)
+}
+
+func TestAncestors_Populate_FetchesOnce(t *testing.T) {
+	store := newTestStore()
+	root := testSpend(t, bt.NewTx())
+	store.add(root, true)
+	a := testSpend(t, root)
+	store.add(a, false)
+	b := testSpend(t, root)
+	b.Outputs[0].Satoshis++ // so that b isn't a
+	store.add(b, false)
+	tip := testSpend(t, a, b, a)
+
+	var ancestors spv.Ancestors
+	if err := ancestors.Populate(context.Background(), store, store, tip,
+		spv.PopulateWorkers(4)); err != nil {
+		t.Fatalf("Failed to populate ancestors : %s", err)
+	}
+
+	if len(ancestors) != 3 {
+		t.Fatalf("Wrong ancestor count : got %d, want 3", len(ancestors))
+	}
+	if store.fetches != 3 {
+		t.Fatalf("Wrong fetch count : got %d, want 3", store.fetches)
+	}
+	for _, tx := range []*bt.Tx{root, a, b} {
+		if _, err := ancestors.Ancestor(*tx.TxHash()); err != nil {
+			t.Fatalf("Missing ancestor %s : %s", tx.TxID(), err)
+		}
+	}
+}
+
+func TestAncestors_Populate_Limits(t *testing.T) {
+	tests := map[string]struct {
+		length int
+		opts   []spv.PopulateOpt
+		expErr error
+	}{
+		"within limits": {
+			length: 5,
+			opts:   []spv.PopulateOpt{spv.PopulateMaxDepth(6), spv.PopulateMaxTxs(6)},
+		},
+		"too deep": {
+			length: 5,
+			opts:   []spv.PopulateOpt{spv.PopulateMaxDepth(5)},
+			expErr: spv.ErrAncestryTooDeep,
+		},
+		"too many txs": {
+			length: 5,
+			opts:   []spv.PopulateOpt{spv.PopulateMaxTxs(5)},
+			expErr: spv.ErrAncestryTooManyTxs,
+		},
+		"too large": {
+			length: 5,
+			opts:   []spv.PopulateOpt{spv.PopulateMaxBytes(100)},
+			expErr: spv.ErrAncestryTooLarge,
+		},
+		"long chain with default limits": {
+			length: 500,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store, tip := testChain(t, test.length)
+
+			var ancestors spv.Ancestors
+			err := ancestors.Populate(context.Background(), store, store, tip, test.opts...)
+			if test.expErr != nil {
+				if !errors.Is(err, test.expErr) {
+					t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to populate ancestors : %s", err)
+			}
+			if len(ancestors) != test.length+1 {
+				t.Fatalf("Wrong ancestor count : got %d, want %d", len(ancestors), test.length+1)
+			}
+		})
+	}
+}
+
+func TestAncestors_Populate_TooLargeGeneration(t *testing.T) {
+	store := newTestStore()
+	var parents []*bt.Tx
+	for i := 0; i < 100; i++ {
+		parent := testSpend(t, bt.NewTx())
+		parent.Outputs[0].Satoshis += uint64(i) // so that each parent is different
+		store.add(parent, true)
+		parents = append(parents, parent)
+	}
+	tip := testSpend(t, parents...)
+
+	// the limit is crossed a few parents into the one generation.
+	maxBytes := 3 * len(parents[0].Bytes())
+	var ancestors spv.Ancestors
+	err := ancestors.Populate(context.Background(), store, store, tip,
+		spv.PopulateMaxBytes(maxBytes), spv.PopulateWorkers(2))
+	if !errors.Is(err, spv.ErrAncestryTooLarge) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrAncestryTooLarge)
+	}
+	if fetches := atomic.LoadInt32(&store.fetches); fetches >= int32(len(parents)) {
+		t.Fatalf("Fetching should stop at the limit : fetched %d of %d", fetches, len(parents))
+	}
+}
+
+func TestAncestors_Populate_Cancelled(t *testing.T) {
+	store, tip := testChain(t, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var ancestors spv.Ancestors
+	err := ancestors.Populate(ctx, store, store, tip)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wrong error : got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestAncestors_Minimize(t *testing.T) {
+	root := testSpend(t, bt.NewTx())
+	a := testSpend(t, root)
+	b := testSpend(t, root)
+	b.Outputs[0].Satoshis++ // so that b isn't a
+	unrelated := testSpend(t, testSpend(t, bt.NewTx(), bt.NewTx()))
+
+	anchored := func(tx *bt.Tx) *spv.Ancestor {
+		return &spv.Ancestor{Tx: tx, Proof: &bc.MerkleProof{TxOrID: tx.TxID()}}
+	}
+
+	tests := map[string]struct {
+		tip        *bt.Tx
+		ancestors  spv.Ancestors
+		expKept    []*bt.Tx
+		expRemoved []*bt.Tx
+	}{
+		"grandparent of anchored parent": {
+			tip:        testSpend(t, a),
+			ancestors:  spv.Ancestors{anchored(root), anchored(a)},
+			expKept:    []*bt.Tx{a},
+			expRemoved: []*bt.Tx{root},
+		},
+		"grandparent of unconfirmed parent": {
+			tip:        testSpend(t, a, b),
+			ancestors:  spv.Ancestors{anchored(root), anchored(a), {Tx: b}, {Tx: unrelated}},
+			expKept:    []*bt.Tx{root, a, b},
+			expRemoved: []*bt.Tx{unrelated},
+		},
+		"duplicate ancestor": {
+			tip:        testSpend(t, a),
+			ancestors:  spv.Ancestors{anchored(a), anchored(a)},
+			expKept:    []*bt.Tx{a},
+			expRemoved: []*bt.Tx{a},
+		},
+		"already minimal": {
+			tip:       testSpend(t, b),
+			ancestors: spv.Ancestors{{Tx: b}, anchored(root)},
+			expKept:   []*bt.Tx{b, root},
+		},
+	}
+
+	txids := func(txs []*bt.Tx) []string {
+		var ids []string
+		for _, tx := range txs {
+			ids = append(ids, tx.TxID())
+		}
+		return ids
+	}
+	ancestorIDs := func(aa spv.Ancestors) []string {
+		var ids []string
+		for _, a := range aa {
+			ids = append(ids, a.Tx.TxID())
+		}
+		return ids
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kept, removed := test.ancestors.Minimize(test.tip)
+			if got, want := ancestorIDs(kept), txids(test.expKept); !equalStrings(got, want) {
+				t.Fatalf("Wrong kept ancestors : got %v, want %v", got, want)
+			}
+			if got, want := ancestorIDs(removed), txids(test.expRemoved); !equalStrings(got, want) {
+				t.Fatalf("Wrong removed ancestors : got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,188 @@
+package spv
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CheckStatus is the result of a check of a tx in a VerificationReport.
+type CheckStatus string
+
+// The results of a check.
+const (
+	// CheckNotRun is the status of a check which was switched off or doesn't apply to
+	// the tx, such as the scripts of an anchored tx.
+	CheckNotRun CheckStatus = "not_run"
+	// CheckPassed is the status of a check which passed.
+	CheckPassed CheckStatus = "passed"
+	// CheckFailed is the status of a check which failed, with the failures recorded in
+	// the TxReport.
+	CheckFailed CheckStatus = "failed"
+	// CheckIncomplete is the status of a check which passed for the inputs it could be
+	// run on, but couldn't be run on all of them as their parents weren't supplied.
+	CheckIncomplete CheckStatus = "incomplete"
+)
+
+// FailureCode is a machine-readable code identifying the reason of a Failure.
+type FailureCode string
+
+// The codes of failures, each matching a sentinel error of the package.
+const (
+	FailureNoInputs          FailureCode = "no_inputs"
+	FailureTipConfirmed      FailureCode = "tip_confirmed"
+	FailureProofOrInput      FailureCode = "proof_or_input_missing"
+	FailureInputMissing      FailureCode = "input_missing"
+	FailureOutputMissing     FailureCode = "output_missing"
+	FailureOutputOutOfBounds FailureCode = "output_out_of_bounds"
+	FailureTxIDMismatch      FailureCode = "txid_mismatch"
+	FailureInvalidProof      FailureCode = "invalid_proof"
+	FailureInvalidScript     FailureCode = "invalid_script"
+	FailureFeeNotEnough      FailureCode = "fee_not_enough"
+	FailureDoubleSpend       FailureCode = "double_spend"
+	FailureUnknown           FailureCode = "unknown"
+)
+
+// failureCodes maps the sentinel errors of the package to their failure codes.
+var failureCodes = []struct {
+	err  error
+	code FailureCode
+}{
+	{ErrNoTxInputsToVerify, FailureNoInputs},
+	{ErrTipTxConfirmed, FailureTipConfirmed},
+	{ErrProofOrInputMissing, FailureProofOrInput},
+	{ErrNotAllInputsSupplied, FailureInputMissing},
+	{ErrMissingOutput, FailureOutputMissing},
+	{ErrInputRefsOutOfBoundsOutput, FailureOutputOutOfBounds},
+	{ErrTxIDMismatch, FailureTxIDMismatch},
+	{ErrInvalidProof, FailureInvalidProof},
+	{ErrInvalidScript, FailureInvalidScript},
+	{ErrFeePaidNotEnough, FailureFeeNotEnough},
+	{ErrDoubleSpend, FailureDoubleSpend},
+}
+
+// A VerificationReport is the result of verifying a payment, covering every tx of its
+// ancestry which was reached, starting with the payment tx.
+type VerificationReport struct {
+	TxID  string      `json:"txid"`
+	Valid bool        `json:"valid"`
+	Txs   []*TxReport `json:"txs"`
+}
+
+// A TxReport is the result of verifying a tx of a payment. BlockHash and BlockHeight are
+// set for anchored txs when they are known from the proof or merkle path.
+//
+// FeeRate is the fee rate paid by the tx in satoshis per byte, set when its fees are
+// checked. EffectiveFeeRate is set when checking package fees and is the rate of the
+// package the tx is mined in or, if it can't be mined, of it and its unmined ancestors.
+type TxReport struct {
+	TxID             string      `json:"txid"`
+	Anchored         bool        `json:"anchored"`
+	BlockHash        string      `json:"blockHash,omitempty"`
+	BlockHeight      *uint64     `json:"blockHeight,omitempty"`
+	Proof            CheckStatus `json:"proof"`
+	Scripts          CheckStatus `json:"scripts"`
+	Fees             CheckStatus `json:"fees"`
+	FeeRate          *float64    `json:"feeRate,omitempty"`
+	EffectiveFeeRate *float64    `json:"effectiveFeeRate,omitempty"`
+	Failures         []*Failure  `json:"failures,omitempty"`
+}
+
+// A Failure is a check of a tx which failed. Input is set when the failure is of one
+// of the inputs of the tx.
+type Failure struct {
+	Code    FailureCode `json:"code"`
+	Input   *int        `json:"input,omitempty"`
+	Message string      `json:"message"`
+
+	err error
+}
+
+// Err returns the error of the failure, which wraps the sentinel error of its code. The
+// error of a failure decoded from JSON is rebuilt from its code and message.
+func (f *Failure) Err() error {
+	if f.err != nil {
+		return f.err
+	}
+
+	return &failureError{message: f.Message, err: failureSentinel(f.Code)}
+}
+
+// A failureError is the error of a failure rebuilt from its code and message.
+type failureError struct {
+	message string
+	err     error
+}
+
+func (e *failureError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the sentinel error of the code of the failure, if it has one.
+func (e *failureError) Unwrap() error {
+	return e.err
+}
+
+// Err returns the error of the first failure of the report, which is the error
+// VerifyPayment would return, or nil if the payment is valid.
+func (r *VerificationReport) Err() error {
+	for _, txr := range r.Txs {
+		for _, f := range txr.Failures {
+			return f.Err()
+		}
+	}
+
+	return nil
+}
+
+// Failures returns every failure of the report.
+func (r *VerificationReport) Failures() []*Failure {
+	var failures []*Failure
+	for _, txr := range r.Txs {
+		failures = append(failures, txr.Failures...)
+	}
+
+	return failures
+}
+
+func newTxReport(txid string) *TxReport {
+	return &TxReport{
+		TxID:    txid,
+		Proof:   CheckNotRun,
+		Scripts: CheckNotRun,
+		Fees:    CheckNotRun,
+	}
+}
+
+// addFailure records err as a failure of the tx, of input idx when it isn't negative.
+func (r *TxReport) addFailure(idx int, err error) {
+	f := &Failure{
+		Code:    failureCode(err),
+		Message: err.Error(),
+		err:     err,
+	}
+	if idx >= 0 {
+		f.Input = &idx
+	}
+	r.Failures = append(r.Failures, f)
+}
+
+// failureCode returns the code of the sentinel error err wraps.
+func failureCode(err error) FailureCode {
+	for _, fc := range failureCodes {
+		if errors.Is(err, fc.err) {
+			return fc.code
+		}
+	}
+
+	return FailureUnknown
+}
+
+// failureSentinel returns the sentinel error of code, or nil if it has none.
+func failureSentinel(code FailureCode) error {
+	for _, fc := range failureCodes {
+		if fc.code == code {
+			return fc.err
+		}
+	}
+
+	return nil
+}
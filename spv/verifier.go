@@ -11,19 +11,23 @@ import (
 
 type verifyOptions struct {
 	// proofs validation
-	proofs   bool
-	script   bool
-	fees     bool
-	feeQuote *bt.FeeQuote
+	proofs      bool
+	script      bool
+	fees        bool
+	packageFees bool
+	feeQuote    *bt.FeeQuote
+	params      *bc.ChainParams
 }
 
 // clone will copy the verifyOptions to a new struct and return it.
 func (v *verifyOptions) clone() *verifyOptions {
 	return &verifyOptions{
-		proofs:   v.proofs,
-		fees:     v.fees,
-		script:   v.script,
-		feeQuote: v.feeQuote,
+		proofs:      v.proofs,
+		fees:        v.fees,
+		packageFees: v.packageFees,
+		script:      v.script,
+		feeQuote:    v.feeQuote,
+		params:      v.params,
 	}
 }
 
@@ -57,6 +61,22 @@ func NoVerifyProofs() VerifyOpt {
 func VerifyFees(fees *bt.FeeQuote) VerifyOpt {
 	return func(opts *verifyOptions) {
 		opts.fees = true
+		opts.packageFees = false
+		opts.feeQuote = fees
+	}
+}
+
+// VerifyPackageFees will make the verifier check the fees of every unconfirmed
+// transaction in the ancestry, not only the supplied transaction, under the package
+// (child pays for parent) rules: a transaction paying too little is accepted when it is
+// mined along with a descendant whose fee covers the combined size of the package.
+//
+// Packages are picked as a miner would, highest fee rate first, so a parent paying
+// enough is mined on its own and can't pay for its children.
+func VerifyPackageFees(fees *bt.FeeQuote) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.fees = true
+		opts.packageFees = true
 		opts.feeQuote = fees
 	}
 }
@@ -66,17 +86,33 @@ func VerifyFees(fees *bt.FeeQuote) VerifyOpt {
 func NoVerifyFees() VerifyOpt {
 	return func(opts *verifyOptions) {
 		opts.fees = false
+		opts.packageFees = false
 		opts.feeQuote = nil
 	}
 }
 
-// VerifyScript will ensure the scripts provided in the transaction are valid.
+// VerifyScript will ensure the scripts of the transaction, and of every unconfirmed
+// transaction in its ancestry, are valid by evaluating each unlocking script against the
+// locking script of the output it spends, including signature checks.
+//
+// Outputs are spent under the script rules of the block they were mined in. The heights
+// of the blocks of ancestors anchored by a merkle proof are looked up when the
+// bc.BlockHeaderChain is a bc.BlockHeightChain, and outputs whose height isn't known
+// are evaluated under the Genesis rules.
 func VerifyScript() VerifyOpt {
 	return func(opts *verifyOptions) {
 		opts.script = true
 	}
 }
 
+// VerifyChainParams sets the network the payment is on, whose Genesis activation height
+// decides the script rules of the outputs spent. Mainnet is used by default.
+func VerifyChainParams(params *bc.ChainParams) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.params = params
+	}
+}
+
 // NoVerifyScript will switch off script verification and rely on
 // mAPI / node verification when the tx is broadcast.
 func NoVerifyScript() VerifyOpt {
@@ -112,6 +148,8 @@ func VerifySPV() VerifyOpt {
 // you are using, some may return a HeaderJSON response others may return the blockhash.
 type PaymentVerifier interface {
 	VerifyPayment(ctx context.Context, p *Payment, opts ...VerifyOpt) error
+	VerifyBEEF(ctx context.Context, beef []byte, opts ...VerifyOpt) error
+	VerifyPaymentReport(ctx context.Context, p *Payment, opts ...VerifyOpt) (*VerificationReport, error)
 	MerkleProofVerifier
 }
 
@@ -119,6 +157,7 @@ type PaymentVerifier interface {
 type MerkleProofVerifier interface {
 	VerifyMerkleProof(context.Context, []byte) (*MerkleProofValidation, error)
 	VerifyMerkleProofJSON(context.Context, *bc.MerkleProof) (bool, bool, error)
+	VerifyMerklePath(context.Context, *bc.MerklePath) (bool, error)
 }
 
 type verifier struct {
@@ -133,12 +172,13 @@ type verifier struct {
 // opts control the global behaviour of the verifier and all options are enabled by default, they are:
 // - ancestry verification (proofs checked etc)
 // - fees checked, ensuring the root tx covers enough fees
-// - script verification which checks the script is correct (not currently implemented).
+// - script verification which checks the scripts of the unconfirmed txs are correct.
 func NewPaymentVerifier(bhc bc.BlockHeaderChain, opts ...VerifyOpt) (PaymentVerifier, error) {
 	o := &verifyOptions{
 		proofs: true,
 		fees:   false,
 		script: true,
+		params: bc.MainNetParams,
 	}
 	for _, opt := range opts {
 		opt(o)
@@ -2,25 +2,15 @@ package spv
 
 import (
 	"context"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/tokenized/go-bt"
 
 	"github.com/tokenized/go-bc"
 )
 
-const (
-	txOrIDFlag      byte = 1 << iota // 1 << 0 which is 00000001
-	targetTypeFlag1                  // 1 << 1 which is 00000010
-	targetTypeFlag2                  // 1 << 2 which is 00000100
-	proofTypeFlag                    // 1 << 3 which is 00001000
-	compositeFlag                    // 1 << 4 which is 00010000
-
-	targetTypeFlags = targetTypeFlag1 | targetTypeFlag2
-)
-
 // MerkleProofValidation is a wrapper for the response of a validation operation.
 type MerkleProofValidation struct {
 	TxID         string
@@ -30,17 +20,15 @@ type MerkleProofValidation struct {
 
 // VerifyMerkleProof verifies a Merkle Proof in standard byte format.
 func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*MerkleProofValidation, error) {
-	mpb, err := parseBinaryMerkleProof(proof)
-	if err != nil {
-		return nil, err
-	}
-
-	err = validateTxOrID(mpb.flags, mpb.txOrID)
-	if err != nil {
+	var mp bc.MerkleProof
+	if err := mp.UnmarshalBinary(proof); err != nil {
+		if errors.Is(err, bc.ErrInvalidMerkleProofFlags) {
+			return nil, ErrInvalidMerkleFlags
+		}
 		return nil, err
 	}
 
-	txid, err := txidFromTxOrID(mpb.txOrID)
+	txid, err := txidFromTxOrID(mp.TxOrID)
 	if err != nil {
 		return nil, err
 	}
@@ -49,62 +37,21 @@ func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*Merkle
 		TxID: txid,
 	}
 
-	var merkleRoot string
-	switch mpb.flags & targetTypeFlags {
-	// if bits 1 and 2 of flags are NOT set, target should contain a block hash (32 bytes)
-	case 0:
-		// The `target` field contains a block hash
-		blockHeader, err := v.bhc.BlockHeader(ctx, mpb.target)
-		if err != nil {
-			return response, err
-		}
-
-		merkleRoot = blockHeader.HashMerkleRootStr()
-
-	// if bit 2 of flags is set, target should contain a merkle root (32 bytes)
-	case 4:
-		// the `target` field contains a merkle root
-		merkleRoot = mpb.target
-
-	// if bit 1 of flags is set, target should contain a block header (80 bytes)
-	case 2:
-		// The `target` field contains a block header
-		var err error
-		merkleRoot, err = bc.ExtractMerkleRootFromBlockHeader(mpb.target)
-		if err != nil {
-			return response, err
-		}
-
-	default:
-		return response, ErrInvalidMerkleFlags
-	}
-
-	if mpb.flags&proofTypeFlag == 1 {
-		return response, ErrInvalidMerkleFlags
-	}
-
-	if mpb.flags&compositeFlag == 1 {
-		return response, ErrInvalidMerkleFlags // composite proof type not supported
-	}
-
-	if txid == "" {
-		return response, ErrMissingTxidInProof
-	}
-
-	if merkleRoot == "" {
-		return response, ErrMissingRootInProof
+	valid, isLastInTree, err := v.VerifyMerkleProofJSON(ctx, &mp)
+	if err != nil {
+		return response, err
 	}
 
-	valid, isLastInTree, err := verifyProof(txid, merkleRoot, mpb.index, mpb.nodes)
-
 	return &MerkleProofValidation{
 		TxID:         txid,
 		Valid:        valid,
 		IsLastInTree: isLastInTree,
-	}, err
+	}, nil
 }
 
-// VerifyMerkleProofJSON verifies a Merkle Proof in standard JSON format.
+// VerifyMerkleProofJSON verifies a Merkle Proof in standard JSON format. Tree and
+// composite proofs are verified for every transaction they contain, with the
+// returned IsLastInTree relating to the transaction of Index and TxOrID.
 func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerkleProof) (bool, bool, error) {
 
 	txid, err := txidFromTxOrID(proof.TxOrID)
@@ -142,14 +89,6 @@ func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerklePr
 		return false, false, errors.New("invalid TargetType or target field")
 	}
 
-	if proof.ProofType != "" && proof.ProofType != "branch" {
-		return false, false, errors.New("only merkle branch supported in this version") // merkle tree proof type not supported
-	}
-
-	if proof.Composite { // OR if (proof.composite && proof.composite != false)
-		return false, false, errors.New("only single proof supported in this version") // composite proof type not supported
-	}
-
 	if txid == "" {
 		return false, false, errors.New("txid missing")
 	}
@@ -158,66 +97,50 @@ func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerklePr
 		return false, false, errors.New("merkleRoot missing")
 	}
 
-	return verifyProof(txid, merkleRoot, proof.Index, proof.Nodes)
-}
-
-func verifyProof(c, merkleRoot string, index uint64, nodes []string) (bool, bool, error) {
-	isLastInTree := true
-
-	for _, p := range nodes {
-		// Check if the node is the left or the right child
-		cIsLeft := index%2 == 0
-
-		// Check for duplicate hash - this happens if the node (p) is
-		// the last element of an uneven merkle tree layer
-		if p == "*" {
-			if !cIsLeft { // this shouldn't happen...
-				return false, false, ErrInvalidNodes
-			}
-			p = c
-		}
-
-		// This check fails at least once if it's not the last element
-		if cIsLeft && c != p {
-			isLastInTree = false
-		}
+	// CalculateRoot checks the nodes fit every transaction of a tree or composite
+	// proof, and that the index of a branch proof is within its nodes.
+	root, isLastInTree, err := proof.CalculateRoot()
+	if err != nil {
+		return false, false, err
+	}
 
-		var err error
-		// Calculate the parent node
-		if cIsLeft {
-			// Concatenate left leaf (c) with right leaf (p)
-			c, err = bc.MerkleTreeParentStr(c, p)
-			if err != nil {
-				return false, false, err
-			}
-		} else {
-			// Concatenate left leaf (p) with right leaf (c)
-			c, err = bc.MerkleTreeParentStr(p, c)
-			if err != nil {
-				return false, false, err
-			}
-		}
+	return root.String() == merkleRoot, isLastInTree, nil
+}
 
-		// We need integer division here with remainder dropped.
-		index = index / 2
+// VerifyMerklePath verifies a BUMP merkle path, checking every txid it holds leads to
+// the merkle root of the block at its height. The bc.BlockHeaderChain of the verifier
+// must implement bc.HeaderAtHeightChain, otherwise ErrNoHeaderAtHeight is returned.
+func (v *verifier) VerifyMerklePath(ctx context.Context, path *bc.MerklePath) (bool, error) {
+	hc, ok := v.bhc.(bc.HeaderAtHeightChain)
+	if !ok {
+		return false, ErrNoHeaderAtHeight
 	}
 
-	// c is now the calculated merkle root
-	return c == merkleRoot, isLastInTree, nil
-}
+	txIDs := path.TxIDs()
+	if len(txIDs) == 0 {
+		return false, ErrMissingTxidInProof
+	}
 
-func validateTxOrID(flags byte, txOrID string) error {
-	// The `txOrId` field contains a full transaction
-	if len(txOrID) > 64 && flags&txOrIDFlag == 0 {
-		return errors.New("expecting txid but got tx")
+	if path.BlockHeight > math.MaxUint32 {
+		return false, fmt.Errorf("block height %d out of range", path.BlockHeight)
 	}
+	blockHeader, err := hc.HeaderAtHeight(uint32(path.BlockHeight))
+	if err != nil {
+		return false, err
+	}
+	merkleRoot := blockHeader.HashMerkleRootStr()
 
-	// The `txOrId` field contains a transaction ID
-	if len(txOrID) == 64 && flags&txOrIDFlag == 1 {
-		return errors.New("expecting tx but got txid")
+	for _, txID := range txIDs {
+		root, err := path.CalculateRoot(txID)
+		if err != nil {
+			return false, err
+		}
+		if root.String() != merkleRoot {
+			return false, nil
+		}
 	}
 
-	return nil
+	return true, nil
 }
 
 func txidFromTxOrID(txOrID string) (string, error) {
@@ -239,90 +162,3 @@ func txidFromTxOrID(txOrID string) (string, error) {
 
 	return "", errors.New("invalid txOrId length - must be at least 64 chars (32 bytes)")
 }
-
-type merkleProofBinary struct {
-	flags  byte
-	index  uint64
-	txOrID string
-	target string
-	nodes  []string
-}
-
-func parseBinaryMerkleProof(proof []byte) (*merkleProofBinary, error) {
-	mpb := &merkleProofBinary{}
-
-	var offset int
-
-	// flags is first byte
-	mpb.flags = proof[offset]
-	offset++
-
-	// index is the next varint after the 1st byte
-	index, size := bt.NewVarIntFromBytes(proof[offset:])
-	mpb.index = uint64(index)
-	offset += size
-
-	var txLength bt.VarInt
-	// if bit 1 of flags is NOT set, txOrId should contain txid (= 32 bytes)
-	if mpb.flags&1 == 0 {
-		txLength = 32
-	}
-
-	// if bit 1 of flags is set, txOrId should contain tx hex (> 32 bytes)
-	if mpb.flags&1 == 1 {
-		// txLength is the next varint after the 1st byte + index size
-		txLength, size = bt.NewVarIntFromBytes(proof[offset:])
-		offset += size
-		if txLength <= 32 {
-			return nil, errors.New("invalid tx length (should be greater than 32 bytes)")
-		}
-	}
-
-	// txOrID is the next txLength bytes after 1st byte + index size (+ txLength size)
-	mpb.txOrID = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+int(txLength)]))
-	offset += int(txLength)
-
-	switch mpb.flags & targetTypeFlags {
-	// if bits 1 and 2 of flags are NOT set, target should contain a block hash (32 bytes)
-	// if bit 2 of flags is set, target should contain a merkle root (32 bytes)
-	case 0, 4:
-		mpb.target = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+32]))
-		offset += 32
-
-	// if bit 1 of flags is set, target should contain a block header (80 bytes)
-	case 2:
-		mpb.target = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+80]))
-		offset += 80
-
-	default:
-		return nil, ErrInvalidMerkleFlags
-	}
-
-	nodeCount, size := bt.NewVarIntFromBytes(proof[offset:])
-	offset += size
-
-	if mpb.index >= 1<<nodeCount {
-		return nil, ErrInvalidProof
-	}
-
-	for i := 0; i < int(nodeCount); i++ {
-		t := proof[offset]
-		offset++
-
-		var n string
-		switch t {
-		case 0:
-			n = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+32]))
-			offset += 32
-		case 1:
-			n = "*"
-
-		default:
-			return nil, fmt.Errorf("invalid value in node type at index: %q", i)
-		}
-
-		mpb.nodes = append(mpb.nodes, n)
-	}
-
-	return mpb, nil
-}
@@ -2,120 +2,482 @@ package spv
 
 import (
 	"context"
+	"math"
 
 	"github.com/pkg/errors"
 	"github.com/tokenized/go-bt"
+	"github.com/tokenized/go-bt/bscript"
 	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/tokenized/go-bc"
 )
 
+// Payment is a payment tx along with its ancestry in binary.
+//
+// When PaymentTx is in Extended Format, as returned by NewExtendedTxFromBytes, its
+// inputs hold the outputs they spend so Ancestors may be empty.
 type Payment struct {
 	PaymentTx *bt.Tx
 	Ancestors []byte
 }
 
+// NewExtendedPayment returns a Payment of the Extended Format tx ef, with its ancestry
+// in binary, which may be empty.
+func NewExtendedPayment(ef []byte, ancestors []byte) (*Payment, error) {
+	tx, err := NewExtendedTxFromBytes(ef)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Payment{PaymentTx: tx, Ancestors: ancestors}, nil
+}
+
 // VerifyPayment is a method for parsing a binary payment transaction and its corresponding ancestry in binary.
 // It will return the paymentTx struct if all validations pass.
 func (v *verifier) VerifyPayment(ctx context.Context, p *Payment, opts ...VerifyOpt) error {
+	var aa Ancestors
+	if len(p.Ancestors) > 0 {
+		if err := aa.ParseBytes(p.Ancestors); err != nil {
+			return err
+		}
+	}
+
+	return v.verifyPayment(ctx, p.PaymentTx, aa, opts...)
+}
+
+// VerifyPaymentReport verifies a payment in the same way as VerifyPayment but rather
+// than stopping at the first failure it carries on through the ancestry, returning a
+// report of the checks run on each tx reached and every failure found.
+//
+// An error is only returned when the payment can't be verified at all, such as when its
+// ancestry can't be parsed or fees are checked without a fee quote.
+func (v *verifier) VerifyPaymentReport(ctx context.Context, p *Payment,
+	opts ...VerifyOpt) (*VerificationReport, error) {
+
+	var aa Ancestors
+	if len(p.Ancestors) > 0 {
+		if err := aa.ParseBytes(p.Ancestors); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &VerificationReport{
+		TxID: p.PaymentTx.TxID(),
+	}
+	if err := v.newAncestryVerifier(aa, report, opts).verifyPayment(ctx, p.PaymentTx); err != nil {
+		return nil, err
+	}
+	report.Valid = report.Err() == nil
+
+	return report, nil
+}
+
+// verifyPayment verifies paymentTx against its ancestors aa.
+func (v *verifier) verifyPayment(ctx context.Context, paymentTx *bt.Tx, aa Ancestors,
+	opts ...VerifyOpt) error {
+
+	return v.newAncestryVerifier(aa, nil, opts).verifyPayment(ctx, paymentTx)
+}
+
+// previousOutput returns the satoshis and locking script of the output spent by input,
+// taken from its parent in the ancestors or, when the input is in Extended Format and
+// the parent isn't present, from the input itself.
+func (e Ancestors) previousOutput(input *bt.Input) (uint64, *bscript.Script, error) {
+	inputID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "previous txid")
+	}
+
+	parent, err := e.Ancestor(*inputID)
+	if err != nil {
+		if input.PreviousTxScript != nil {
+			return input.PreviousTxSatoshis, input.PreviousTxScript, nil
+		}
+		return 0, nil, errors.Wrap(err, "missing tx")
+	}
+
+	out := parent.Tx.OutputIdx(int(input.PreviousTxOutIndex))
+	if out == nil {
+		return 0, nil, ErrMissingOutput
+	}
+
+	return out.Satoshis, out.LockingScript, nil
+}
+
+// An ancestryVerifier walks the ancestry of a payment from the payment tx to its
+// anchored ancestors. With proofs set it verifies the proof of each anchored ancestor
+// reached, and with script set the scripts of every input of the unanchored txs.
+//
+// Without a report it stops at the first failure, returning its error. With a report
+// each failure is recorded in it and the walk carries on.
+type ancestryVerifier struct {
+	v      *verifier
+	aa     Ancestors
+	o      *verifyOptions
+	report *VerificationReport
+
+	// verified holds the ancestors already verified, so that those reached through
+	// more than one input are only walked once.
+	verified map[bitcoin.Hash32]bool
+	// paths holds the results of the merkle paths already verified, which may be
+	// shared by the ancestors confirmed in the same block when taken from a BEEF.
+	paths map[*bc.MerklePath]error
+	// txReports holds the report of each tx reached, shared by the checks walking the
+	// ancestry separately.
+	txReports map[bitcoin.Hash32]*TxReport
+}
+
+func (v *verifier) newAncestryVerifier(aa Ancestors, report *VerificationReport,
+	opts []VerifyOpt) *ancestryVerifier {
+
 	o := v.opts.clone()
 	for _, opt := range opts {
 		opt(o)
 	}
-	if o.proofs && v == nil {
-		return errors.New("Merkle Proof Verifier is required when proofs is set")
+
+	return &ancestryVerifier{
+		v:         v,
+		aa:        aa,
+		o:         o,
+		report:    report,
+		verified:  make(map[bitcoin.Hash32]bool),
+		paths:     make(map[*bc.MerklePath]error),
+		txReports: make(map[bitcoin.Hash32]*TxReport),
+	}
+}
+
+// fail records err as a failure of input idx of the tx of txr, or of the tx itself when
+// idx is negative. It returns err when there is no report to record it in.
+func (av *ancestryVerifier) fail(txr *TxReport, idx int, err error) error {
+	if av.report == nil {
+		return err
 	}
+	txr.addFailure(idx, err)
 
-	var aa Ancestors
-	if err := aa.ParseBytes(p.Ancestors); err != nil {
+	return nil
+}
+
+// txReport returns the TxReport of the tx, creating it and adding it to the report if
+// there is one the first time the tx is reached.
+func (av *ancestryVerifier) txReport(tx *bt.Tx) *TxReport {
+	if txr, ok := av.txReports[*tx.TxHash()]; ok {
+		return txr
+	}
+
+	txr := newTxReport(tx.TxID())
+	av.txReports[*tx.TxHash()] = txr
+	if av.report != nil {
+		av.report.Txs = append(av.report.Txs, txr)
+	}
+
+	return txr
+}
+
+// verifyPayment verifies paymentTx and, as enabled by the options, its fees and
+// ancestry.
+func (av *ancestryVerifier) verifyPayment(ctx context.Context, paymentTx *bt.Tx) error {
+	if av.o.proofs && av.v.bhc == nil {
+		return ErrNoBlockHeaderChain
+	}
+	if av.o.fees && av.o.feeQuote == nil {
+		return ErrNoFeeQuoteSupplied
+	}
+
+	txr := av.txReport(paymentTx)
+	if len(paymentTx.Inputs) == 0 {
+		return av.fail(txr, -1, ErrNoTxInputsToVerify)
+	}
+	if tip, err := av.aa.Ancestor(*paymentTx.TxHash()); err == nil && tip.IsAnchored() {
+		if err := av.fail(txr, -1, ErrTipTxConfirmed); err != nil {
+			return err
+		}
+	}
+	if err := av.verifyDoubleSpends(paymentTx); err != nil {
 		return err
 	}
 
-	var paymentTxID [32]byte
-	copy(paymentTxID[:], p.PaymentTx.TxIDBytes())
+	if av.o.packageFees {
+		if err := av.verifyPackageFees(paymentTx, txr); err != nil {
+			return err
+		}
+	} else if av.o.fees {
+		if err := av.verifyFees(paymentTx, txr); err != nil {
+			return err
+		}
+	}
+
+	if av.o.proofs || av.o.script {
+		if err := av.verifyInputs(ctx, paymentTx, txr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyFees checks tx pays enough fees for the fee quote, filling the previous
+// satoshis of its inputs from the outputs they spend.
+func (av *ancestryVerifier) verifyFees(tx *bt.Tx, txr *TxReport) error {
+	txr.Fees = CheckFailed
+	for i, input := range tx.Inputs {
+		satoshis, _, err := av.aa.previousOutput(input)
+		if err != nil {
+			return av.fail(txr, i, errors.Wrapf(err, "input %d", i))
+		}
+
+		input.PreviousTxSatoshis = satoshis
+	}
+
+	ok, err := tx.IsFeePaidEnough(av.o.feeQuote)
+	if err != nil {
+		return av.fail(txr, -1, err)
+	}
+	if in, out := tx.TotalInputSatoshis(), tx.TotalOutputSatoshis(); in >= out {
+		rate := feeRate(in-out, uint64(len(tx.Bytes())))
+		txr.FeeRate = &rate
+	}
+	if !ok {
+		return av.fail(txr, -1, ErrFeePaidNotEnough)
+	}
+	txr.Fees = CheckPassed
+
+	return nil
+}
+
+// verifyInputs verifies the inputs of tx and the ancestors they spend. When verifying
+// proofs ErrProofOrInputMissing is returned if the ancestry breaks before reaching an
+// anchored ancestor, otherwise the inputs spending missing ancestors are skipped unless
+// they are in Extended Format, which is enough to check their scripts.
+func (av *ancestryVerifier) verifyInputs(ctx context.Context, tx *bt.Tx, txr *TxReport) error {
+	if len(tx.Inputs) == 0 {
+		return av.fail(txr, -1, errors.Wrapf(ErrNoTxInputsToVerify, "tx %s", tx.TxID()))
+	}
+	if av.o.script {
+		txr.Scripts = CheckPassed
+	}
+
+	for idx, input := range tx.Inputs {
+		inputID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+		if err != nil {
+			return errors.Wrapf(err, "input %d of tx %s", idx, tx.TxID())
+		}
 
-	if o.fees {
-		if o.feeQuote == nil {
-			return ErrNoFeeQuoteSupplied
+		parent, err := av.aa.Ancestor(*inputID)
+		if err != nil {
+			if av.o.proofs {
+				if err := av.fail(txr, idx, errors.Wrapf(ErrProofOrInputMissing,
+					"input %d of tx %s spends %s", idx, tx.TxID(), inputID)); err != nil {
+					return err
+				}
+			}
+			if !av.o.script {
+				continue
+			}
+			if input.PreviousTxScript == nil {
+				if txr.Scripts == CheckPassed {
+					txr.Scripts = CheckIncomplete
+				}
+				continue
+			}
+			prevOut := &bt.Output{
+				Satoshis:      input.PreviousTxSatoshis,
+				LockingScript: input.PreviousTxScript,
+			}
+			if err := av.verifyScript(tx, idx, prevOut, false, txr); err != nil {
+				return err
+			}
+			continue
 		}
-		for i, input := range p.PaymentTx.Inputs {
-			var inputID bitcoin.Hash32
-			copy(inputID[:], input.PreviousTxID())
-			parent, err := aa.Ancestor(inputID)
-			if err != nil {
-				return errors.Wrapf(err, "missing tx for input %d", i)
+
+		if int(input.PreviousTxOutIndex) >= len(parent.Tx.Outputs) {
+			if err := av.fail(txr, idx, errors.Wrapf(ErrInputRefsOutOfBoundsOutput,
+				"input %d of tx %s spends %s:%d", idx, tx.TxID(), inputID,
+				input.PreviousTxOutIndex)); err != nil {
+				return err
+			}
+			if av.o.script {
+				txr.Scripts = CheckFailed
 			}
+		} else if av.o.script {
+			if err := av.verifyScript(tx, idx, parent.Tx.Outputs[input.PreviousTxOutIndex],
+				av.isPreGenesis(ctx, parent), txr); err != nil {
+				return err
+			}
+		}
 
-			out := parent.Tx.OutputIdx(int(input.PreviousTxOutIndex))
-			if out == nil {
-				return ErrMissingOutput
+		if av.verified[*inputID] {
+			continue
+		}
+		av.verified[*inputID] = true
+
+		ptxr := av.txReport(parent.Tx)
+		if parent.IsAnchored() {
+			ptxr.Anchored = true
+			if av.o.proofs {
+				if err := av.verifyAnchor(ctx, parent, ptxr); err != nil {
+					return err
+				}
 			}
+		} else if err := av.verifyInputs(ctx, parent.Tx, ptxr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyScript verifies the script of input idx of tx, which spends prevOut.
+func (av *ancestryVerifier) verifyScript(tx *bt.Tx, idx int, prevOut *bt.Output, preGenesis bool,
+	txr *TxReport) error {
+
+	if err := verifyInputScript(tx, idx, prevOut, preGenesis); err != nil {
+		txr.Scripts = CheckFailed
+		return av.fail(txr, idx, err)
+	}
+
+	return nil
+}
+
+// verifyAnchor verifies the Proof or MerklePath of an anchored ancestor, failing with
+// ErrTxIDMismatch if it is for another tx and ErrInvalidProof if it doesn't lead to a
+// block of the chain.
+func (av *ancestryVerifier) verifyAnchor(ctx context.Context, a *Ancestor, txr *TxReport) error {
+	txr.Proof = CheckFailed
+	txid := a.Tx.TxID()
 
-			input.PreviousTxSatoshis = out.Satoshis
+	if a.Proof != nil {
+		if av.report != nil {
+			txr.BlockHash = proofBlockHash(a.Proof)
+			if height, ok := av.proofBlockHeight(ctx, a.Proof); ok {
+				h := uint64(height)
+				txr.BlockHeight = &h
+			}
+		}
+		if !proofHasTx(a.Proof, txid) {
+			return av.fail(txr, -1, errors.Wrapf(ErrTxIDMismatch, "proof of tx %s", txid))
 		}
-		ok, err := p.PaymentTx.IsFeePaidEnough(o.feeQuote)
+		valid, _, err := av.v.VerifyMerkleProofJSON(ctx, a.Proof)
 		if err != nil {
+			return av.fail(txr, -1, errors.Wrapf(ErrInvalidProof, "tx %s: %s", txid, err))
+		}
+		if !valid {
+			return av.fail(txr, -1, errors.Wrapf(ErrInvalidProof, "tx %s", txid))
+		}
+		txr.Proof = CheckPassed
+
+		return nil
+	}
+
+	if av.report != nil {
+		height := a.MerklePath.BlockHeight
+		txr.BlockHeight = &height
+		txr.BlockHash = av.pathBlockHash(a.MerklePath)
+	}
+	if !pathHasTx(a.MerklePath, bc.Hash(*a.Tx.TxHash())) {
+		return av.fail(txr, -1, errors.Wrapf(ErrTxIDMismatch, "merkle path of tx %s", txid))
+	}
+
+	pathErr, verified := av.paths[a.MerklePath]
+	if !verified {
+		valid, err := av.v.VerifyMerklePath(ctx, a.MerklePath)
+		if errors.Cause(err) == ErrNoHeaderAtHeight {
 			return err
 		}
-		if !ok {
-			return ErrFeePaidNotEnough
-		}
-	}
-	// for _, a := range aa {
-	// 	inputsToCheck := make(map[[32]byte]*extendedInput)
-	// 	if len(a.Tx.Inputs) == 0 {
-	// 		return ErrNoTxInputsToVerify
-	// 	}
-	// 	for idx, input := range a.Tx.Inputs {
-	// 		var inputID [32]byte
-	// 		copy(inputID[:], input.PreviousTxID())
-	// 		inputsToCheck[inputID] = &extendedInput{
-	// 			input: input,
-	// 			vin:   idx,
-	// 		}
-	// 	}
-	// 	// if we have a proof, check it.
-	// 	if o.proofs {
-	// 		if a.Proof == nil {
-	// 			for inputID := range inputsToCheck {
-	// 				// check if we have that ancestry, if not validation fail.
-	// 				if aa[inputID] == nil {
-	// 					return ErrProofOrInputMissing
-	// 				}
-	// 			}
-	// 		} else {
-	// 			// check proof.
-	// 			response, err := v.VerifyMerkleProof(ctx, a.Proof)
-	// 			if response == nil {
-	// 				return ErrInvalidProof
-	// 			}
-	// 			if response.TxID != "" && response.TxID != a.Tx.TxID() {
-	// 				return ErrTxIDMismatch
-	// 			}
-	// 			if err != nil || !response.Valid {
-	// 				return ErrInvalidProof
-	// 			}
-	// 		}
-	// 	}
-	// 	if o.script {
-	// 		// otherwise check the inputs.
-	// 		for inputID, extendedInput := range inputsToCheck {
-	// 			input := extendedInput.input
-	// 			// check if we have that ancestry, if not validation fail.
-	// 			if aa[inputID] == nil {
-	// 				if a.Proof == nil && o.proofs {
-	// 					return ErrProofOrInputMissing
-	// 				}
-	// 				continue
-	// 			}
-	// 			if len(aa[inputID].Tx.Outputs) <= int(input.PreviousTxOutIndex) {
-	// 				return ErrInputRefsOutOfBoundsOutput
-	// 			}
-	// 			lockingScript := aa[inputID].Tx.Outputs[input.PreviousTxOutIndex].LockingScript
-	// 			unlockingScript := input.UnlockingScript
-	// 			if !verifyInputOutputPair(a.Tx, lockingScript, unlockingScript) {
-	// 				return ErrPaymentNotVerified
-	// 			}
-	// 		}
-	// 	}
-	// }
+		if err == nil && !valid {
+			err = errors.New("merkle root not of block")
+		}
+		pathErr = err
+		av.paths[a.MerklePath] = pathErr
+	}
+	if pathErr != nil {
+		return av.fail(txr, -1, errors.Wrapf(ErrInvalidProof, "tx %s: %s", txid, pathErr))
+	}
+	txr.Proof = CheckPassed
+
 	return nil
 }
+
+// proofBlockHash returns the hash of the block a merkle proof targets, or an empty
+// string if it targets a merkle root or its target is malformed.
+func proofBlockHash(proof *bc.MerkleProof) string {
+	switch proof.TargetType {
+	case "", "hash":
+		return proof.Target
+	case "header":
+		header, err := bc.NewBlockHeaderFromStr(proof.Target)
+		if err != nil {
+			return ""
+		}
+		hash, err := header.Hash()
+		if err != nil {
+			return ""
+		}
+		return hash.String()
+	}
+
+	return ""
+}
+
+// proofBlockHeight returns the height of the block proof is of, which can only be looked
+// up when the bc.BlockHeaderChain is a bc.BlockHeightChain, and whether it is known.
+func (av *ancestryVerifier) proofBlockHeight(ctx context.Context, proof *bc.MerkleProof) (uint32, bool) {
+	hc, ok := av.v.bhc.(bc.BlockHeightChain)
+	if !ok {
+		return 0, false
+	}
+	blockHash := proofBlockHash(proof)
+	if blockHash == "" {
+		return 0, false
+	}
+	height, err := hc.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return 0, false
+	}
+
+	return height, true
+}
+
+// pathBlockHash returns the hash of the block at the height of a merkle path, or an
+// empty string if it can't be looked up.
+func (av *ancestryVerifier) pathBlockHash(path *bc.MerklePath) string {
+	hc, ok := av.v.bhc.(bc.HeaderAtHeightChain)
+	if !ok || path.BlockHeight > math.MaxUint32 {
+		return ""
+	}
+	header, err := hc.HeaderAtHeight(uint32(path.BlockHeight))
+	if err != nil {
+		return ""
+	}
+	hash, err := header.Hash()
+	if err != nil {
+		return ""
+	}
+
+	return hash.String()
+}
+
+// proofHasTx returns true if txid is the tx of the proof or, for a composite proof,
+// one of its txs.
+func proofHasTx(proof *bc.MerkleProof, txid string) bool {
+	if id, err := txidFromTxOrID(proof.TxOrID); err == nil && id == txid {
+		return true
+	}
+	for _, ptx := range proof.Txs {
+		if id, err := txidFromTxOrID(ptx.TxOrID); err == nil && id == txid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathHasTx returns true if txid is one of the txids of interest of the merkle path.
+func pathHasTx(path *bc.MerklePath, txid bc.Hash) bool {
+	for _, id := range path.TxIDs() {
+		if id == txid {
+			return true
+		}
+	}
+
+	return false
+}
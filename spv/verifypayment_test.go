@@ -0,0 +1,604 @@
+package spv_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tokenized/go-bc/spv"
+	"github.com/tokenized/go-bc/testing/data"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/go-bt/bscript"
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/tokenized/go-bc"
+)
+
+// mockBlockHeaderChain returns the headers of testing/data/bhc, keyed by block hash.
+type mockBlockHeaderChain struct{}
+
+func (m *mockBlockHeaderChain) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	b, err := data.BlockHeaderData.Load(blockHash)
+	if err != nil {
+		return nil, bc.ErrHeaderNotFound
+	}
+
+	return bc.NewBlockHeaderFromStr(string(b[:160]))
+}
+
+// mockBlockHeightChain is a mockBlockHeaderChain which puts every block at height, so
+// that the script rules of the outputs of ancestors anchored by proofs are known.
+type mockBlockHeightChain struct {
+	mockBlockHeaderChain
+	height uint32
+}
+
+func (m *mockBlockHeightChain) BlockHeight(ctx context.Context, blockHash string) (uint32, error) {
+	if _, err := m.BlockHeader(ctx, blockHash); err != nil {
+		return 0, err
+	}
+
+	return m.height, nil
+}
+
+// verifyFixtureTx is a tx of the nested envelopes of testing/data/spv/verify, with
+// its parents keyed by txid.
+type verifyFixtureTx struct {
+	RawTx   string                      `json:"rawTx"`
+	Proof   *bc.MerkleProof             `json:"proof"`
+	Parents map[string]*verifyFixtureTx `json:"parents"`
+}
+
+// loadVerifyFixture returns the payment tx of a nested envelope along with its
+// ancestry, flattened into spv.Ancestors. A proof of the payment tx itself is kept
+// by adding the payment tx to the ancestors.
+func loadVerifyFixture(t *testing.T, file string) (*bt.Tx, spv.Ancestors) {
+	b, err := data.SpvVerifyData.Load(file)
+	if err != nil {
+		t.Fatalf("Failed to load data : %s", err)
+	}
+
+	var fixture struct {
+		Data verifyFixtureTx `json:"data"`
+	}
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		t.Fatalf("Failed to unmarshal data : %s", err)
+	}
+
+	tx, err := bt.NewTxFromString(fixture.Data.RawTx)
+	if err != nil {
+		t.Fatalf("Failed to parse tx : %s", err)
+	}
+
+	var ancestors spv.Ancestors
+	seen := make(map[string]bool)
+	var flatten func(ftx *verifyFixtureTx, tx *bt.Tx)
+	flatten = func(ftx *verifyFixtureTx, tx *bt.Tx) {
+		if ftx.Proof != nil && !seen[tx.TxID()] {
+			seen[tx.TxID()] = true
+			ancestors = append(ancestors, &spv.Ancestor{Tx: tx, Proof: ftx.Proof})
+		}
+		for _, parent := range ftx.Parents {
+			ptx, err := bt.NewTxFromString(parent.RawTx)
+			if err != nil {
+				t.Fatalf("Failed to parse parent tx : %s", err)
+			}
+			if parent.Proof == nil && !seen[ptx.TxID()] {
+				seen[ptx.TxID()] = true
+				ancestors = append(ancestors, &spv.Ancestor{Tx: ptx})
+			}
+			flatten(parent, ptx)
+		}
+	}
+	flatten(&fixture.Data, tx)
+
+	return tx, ancestors
+}
+
+func TestVerifier_VerifyPayment(t *testing.T) {
+	tests := map[string]struct {
+		file   string
+		expErr error
+	}{
+		"valid": {
+			file: "valid.json",
+		},
+		"valid deep": {
+			file: "valid_deep.json",
+		},
+		"valid with tx hex in proofs": {
+			file: "valid_merkle_proof_hex.json",
+		},
+		"confirmed payment tx": {
+			file:   "invalid_confirmed_root.json",
+			expErr: spv.ErrTipTxConfirmed,
+		},
+		"wrong proof index deep in ancestry": {
+			file:   "invalid_deep_merkle_proof_index.json",
+			expErr: spv.ErrInvalidProof,
+		},
+		"missing proof deep in ancestry": {
+			file:   "invalid_deep_missing_merkle_proof.json",
+			expErr: spv.ErrProofOrInputMissing,
+		},
+		"missing parent deep in ancestry": {
+			file:   "invalid_deep_parent_missing.json",
+			expErr: spv.ErrProofOrInputMissing,
+		},
+		"ancestor without inputs": {
+			file:   "invalid_deep_tx_missing_inputs.json",
+			expErr: spv.ErrNoTxInputsToVerify,
+		},
+		"proof of another tx deep in ancestry": {
+			file:   "invalid_deep_wrong_merkle_proof.json",
+			expErr: spv.ErrTxIDMismatch,
+		},
+		"wrong proof index": {
+			file:   "invalid_merkle_proof.json",
+			expErr: spv.ErrInvalidProof,
+		},
+		"missing proof": {
+			file:   "invalid_missing_merkle_proof.json",
+			expErr: spv.ErrProofOrInputMissing,
+		},
+		"missing parents": {
+			file:   "invalid_missing_parents.json",
+			expErr: spv.ErrProofOrInputMissing,
+		},
+		"input spends output out of bounds": {
+			file:   "invalid_tx_indexing_oob.json",
+			expErr: spv.ErrInputRefsOutOfBoundsOutput,
+		},
+		"payment tx without inputs": {
+			file:   "invalid_tx_missing_inputs.json",
+			expErr: spv.ErrNoTxInputsToVerify,
+		},
+		"proof of another tx": {
+			file:   "invalid_wrong_merkle_proof.json",
+			expErr: spv.ErrTxIDMismatch,
+		},
+		"proof of another tx as hex": {
+			file:   "invalid_wrong_merkle_proof_hex.json",
+			expErr: spv.ErrTxIDMismatch,
+		},
+		"parents not spent by payment tx": {
+			file:   "invalid_wrong_parent.json",
+			expErr: spv.ErrProofOrInputMissing,
+		},
+	}
+
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifyScript())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx, ancestors := loadVerifyFixture(t, test.file)
+			b, err := ancestors.Bytes()
+			if err != nil {
+				t.Fatalf("Failed to marshal ancestors : %s", err)
+			}
+
+			err = verifier.VerifyPayment(context.Background(), &spv.Payment{
+				PaymentTx: tx,
+				Ancestors: b,
+			})
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("Failed to verify payment : %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPayment_Scripts(t *testing.T) {
+	tests := map[string]struct {
+		file   string
+		modify func(tx *bt.Tx)
+		expErr error
+	}{
+		"valid": {
+			file: "valid.json",
+		},
+		"valid deep": {
+			file: "valid_deep.json",
+		},
+		"unlocking script doesn't satisfy locking script": {
+			file: "valid.json",
+			modify: func(tx *bt.Tx) {
+				tx.Inputs[0].UnlockingScript = bscript.NewFromBytes([]byte{bscript.OpTRUE})
+			},
+			expErr: spv.ErrInvalidScript,
+		},
+		"signature doesn't commit to tx": {
+			file: "valid.json",
+			modify: func(tx *bt.Tx) {
+				tx.Outputs[0].Satoshis++
+			},
+			expErr: spv.ErrInvalidScript,
+		},
+	}
+
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeightChain{height: 700000},
+		spv.VerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx, ancestors := loadVerifyFixture(t, test.file)
+			if test.modify != nil {
+				test.modify(tx)
+			}
+			b, err := ancestors.Bytes()
+			if err != nil {
+				t.Fatalf("Failed to marshal ancestors : %s", err)
+			}
+
+			err = verifier.VerifyPayment(context.Background(), &spv.Payment{
+				PaymentTx: tx,
+				Ancestors: b,
+			})
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("Failed to verify payment : %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPayment_NoBlockHeaderChain(t *testing.T) {
+	tx, ancestors := loadVerifyFixture(t, "valid.json")
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	payment := &spv.Payment{PaymentTx: tx, Ancestors: b}
+
+	verifier, err := spv.NewPaymentVerifier(nil, spv.NoVerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+	if err := verifier.VerifyPayment(context.Background(), payment); err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+
+	// proofs enabled per call need the chain the verifier doesn't have.
+	err = verifier.VerifyPayment(context.Background(), payment, spv.VerifyProofs())
+	if !errors.Is(err, spv.ErrNoBlockHeaderChain) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrNoBlockHeaderChain)
+	}
+	_, err = verifier.VerifyPaymentReport(context.Background(), payment, spv.VerifyProofs())
+	if !errors.Is(err, spv.ErrNoBlockHeaderChain) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrNoBlockHeaderChain)
+	}
+}
+
+func TestVerifier_VerifyPaymentReport_ScriptsUnknownHeight(t *testing.T) {
+	tx, ancestors := loadVerifyFixture(t, "valid.json")
+	tx.Inputs[0].UnlockingScript = bscript.NewFromBytes([]byte{bscript.OpTRUE})
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	payment := &spv.Payment{PaymentTx: tx, Ancestors: b}
+
+	// the heights of the blocks of the parents are unknown, so their outputs are
+	// evaluated under the Genesis rules.
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.VerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+	if err := verifier.VerifyPayment(context.Background(), payment); !errors.Is(err,
+		spv.ErrInvalidScript) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrInvalidScript)
+	}
+	report, err := verifier.VerifyPaymentReport(context.Background(), payment)
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if report.Valid || report.Txs[0].Scripts != spv.CheckFailed ||
+		!errors.Is(report.Err(), spv.ErrInvalidScript) {
+		t.Fatalf("Scripts should fail : %+v", report.Txs[0])
+	}
+
+	// pre-Genesis outputs are evaluated under the rules from before Genesis.
+	verifier, err = spv.NewPaymentVerifier(&mockBlockHeightChain{height: 600000},
+		spv.VerifySPV())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+	report, err = verifier.VerifyPaymentReport(context.Background(), payment)
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if report.Valid || report.Txs[0].Scripts != spv.CheckFailed ||
+		!errors.Is(report.Err(), spv.ErrInvalidScript) {
+		t.Fatalf("Scripts should fail : %+v", report.Txs[0])
+	}
+}
+
+func TestVerifier_VerifyPayment_ScriptsGenesisHeight(t *testing.T) {
+	// the parent output adds to a 5 byte number, which is only allowed after Genesis.
+	root := testSpend(t, bt.NewTx())
+	root.Outputs[0].LockingScript = bscript.NewFromBytes([]byte{0x05, 0, 0, 0, 0, 0x01,
+		bscript.Op1ADD, bscript.OpDROP})
+	proof := testProof(t, root)
+	proof.Target = "6f2c5a14033b6082fb160cc2603d2047f30df4bcc07b506c5de97dd9b10d4477"
+	proof.TargetType = ""
+
+	ancestors := spv.Ancestors{{Tx: root, Proof: proof}}
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	payment := &spv.Payment{PaymentTx: testSpend(t, root), Ancestors: b}
+
+	tests := map[string]struct {
+		height uint32
+		opts   []spv.VerifyOpt
+		expErr error
+	}{
+		"mainnet after Genesis": {
+			height: 700000,
+		},
+		"mainnet before Genesis": {
+			height: 600000,
+			expErr: spv.ErrInvalidScript,
+		},
+		"testnet before Genesis": {
+			height: 700000,
+			opts:   []spv.VerifyOpt{spv.VerifyChainParams(bc.TestNetParams)},
+			expErr: spv.ErrInvalidScript,
+		},
+		"regtest after Genesis": {
+			height: 10000,
+			opts:   []spv.VerifyOpt{spv.VerifyChainParams(bc.RegTestParams)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verifier, err := spv.NewPaymentVerifier(&mockBlockHeightChain{height: test.height},
+				append([]spv.VerifyOpt{spv.NoVerifyProofs(), spv.VerifyScript()}, test.opts...)...)
+			if err != nil {
+				t.Fatalf("Failed to create verifier : %s", err)
+			}
+
+			err = verifier.VerifyPayment(context.Background(), payment)
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("Failed to verify payment : %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expErr) {
+				t.Fatalf("Wrong error : got %v, want %v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPayment_Extended(t *testing.T) {
+	tx, ancestors := loadVerifyFixture(t, "valid.json")
+	for _, input := range tx.Inputs {
+		inputID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+		if err != nil {
+			t.Fatalf("Failed to parse input txid : %s", err)
+		}
+		parent, err := ancestors.Ancestor(*inputID)
+		if err != nil {
+			t.Fatalf("Failed to find parent : %s", err)
+		}
+		out := parent.Tx.Outputs[input.PreviousTxOutIndex]
+		input.PreviousTxSatoshis = out.Satoshis
+		input.PreviousTxScript = out.LockingScript
+	}
+	ef, err := spv.ExtendedTxBytes(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode extended tx : %s", err)
+	}
+
+	payment, err := spv.NewExtendedPayment(ef, nil)
+	if err != nil {
+		t.Fatalf("Failed to create extended payment : %s", err)
+	}
+
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifyProofs(),
+		spv.VerifyScript())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+	if err := verifier.VerifyPayment(context.Background(), payment); err != nil {
+		t.Fatalf("Failed to verify extended payment : %s", err)
+	}
+
+	// the signatures commit to the previous satoshis so a wrong amount fails.
+	payment.PaymentTx.Inputs[0].PreviousTxSatoshis++
+	err = verifier.VerifyPayment(context.Background(), payment)
+	if !errors.Is(err, spv.ErrInvalidScript) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrInvalidScript)
+	}
+
+	// proofs can't be verified without the ancestry.
+	err = verifier.VerifyPayment(context.Background(), payment, spv.VerifyProofs())
+	if !errors.Is(err, spv.ErrProofOrInputMissing) {
+		t.Fatalf("Wrong error : got %v, want %v", err, spv.ErrProofOrInputMissing)
+	}
+}
+
+func TestVerifier_VerifyPaymentReport(t *testing.T) {
+	verifier, err := spv.NewPaymentVerifier(&mockBlockHeaderChain{}, spv.NoVerifyScript())
+	if err != nil {
+		t.Fatalf("Failed to create verifier : %s", err)
+	}
+
+	tx, ancestors := loadVerifyFixture(t, "valid.json")
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	report, err := verifier.VerifyPaymentReport(context.Background(), &spv.Payment{
+		PaymentTx: tx,
+		Ancestors: b,
+	})
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if !report.Valid || report.Err() != nil {
+		t.Fatalf("Payment should be valid : %v", report.Err())
+	}
+	if len(report.Txs) != len(ancestors)+1 {
+		t.Fatalf("Wrong tx count : got %d, want %d", len(report.Txs), len(ancestors)+1)
+	}
+	for _, txr := range report.Txs[1:] {
+		if !txr.Anchored || txr.Proof != spv.CheckPassed || txr.BlockHash == "" {
+			t.Fatalf("Ancestor %s should have a passed proof : %+v", txr.TxID, txr)
+		}
+		if txr.Scripts != spv.CheckNotRun || txr.Fees != spv.CheckNotRun {
+			t.Fatalf("Ancestor %s should only have its proof checked : %+v", txr.TxID, txr)
+		}
+	}
+
+	// without proofs every parent breaks the ancestry, and all are reported.
+	for _, a := range ancestors {
+		a.Proof = nil
+	}
+	b, err = ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	report, err = verifier.VerifyPaymentReport(context.Background(), &spv.Payment{
+		PaymentTx: tx,
+		Ancestors: b,
+	})
+	if err != nil {
+		t.Fatalf("Failed to verify payment : %s", err)
+	}
+	if report.Valid || !errors.Is(report.Err(), spv.ErrProofOrInputMissing) {
+		t.Fatalf("Wrong error : got %v, want %v", report.Err(), spv.ErrProofOrInputMissing)
+	}
+
+	// the ancestry breaks at each input spending a tx which isn't an ancestor.
+	txs := []*bt.Tx{tx}
+	for _, a := range ancestors {
+		txs = append(txs, a.Tx)
+	}
+	expFailures := make(map[string]bool)
+	for _, ptx := range txs {
+		for i, input := range ptx.Inputs {
+			inputID, err := bitcoin.NewHash32(bt.ReverseBytes(input.PreviousTxID()))
+			if err != nil {
+				t.Fatalf("Failed to parse input txid : %s", err)
+			}
+			if _, err := ancestors.Ancestor(*inputID); err != nil {
+				expFailures[fmt.Sprintf("%s:%d", ptx.TxID(), i)] = true
+			}
+		}
+	}
+	failures := report.Failures()
+	if len(failures) != len(expFailures) {
+		t.Fatalf("Wrong failure count : got %d, want %d", len(failures), len(expFailures))
+	}
+	for _, txr := range report.Txs {
+		for _, f := range txr.Failures {
+			if f.Code != spv.FailureProofOrInput || f.Input == nil {
+				t.Fatalf("Wrong failure : %+v", f)
+			}
+			input := fmt.Sprintf("%s:%d", txr.TxID, *f.Input)
+			if !expFailures[input] {
+				t.Fatalf("Unexpected failure of input %s : %s", input, f.Message)
+			}
+			delete(expFailures, input)
+		}
+	}
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed to marshal report : %s", err)
+	}
+	var decoded spv.VerificationReport
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal report : %s", err)
+	}
+	if decoded.TxID != tx.TxID() || len(decoded.Failures()) != len(failures) {
+		t.Fatalf("Wrong report after JSON round trip : %s", js)
+	}
+
+	// the errors of the failures are rebuilt from their codes and messages.
+	if err := decoded.Err(); !errors.Is(err, spv.ErrProofOrInputMissing) ||
+		err.Error() != report.Err().Error() {
+		t.Fatalf("Wrong error after JSON round trip : got %v, want %v", err, report.Err())
+	}
+	for _, f := range decoded.Failures() {
+		if !errors.Is(f.Err(), spv.ErrProofOrInputMissing) || f.Err().Error() != f.Message {
+			t.Fatalf("Wrong failure error after JSON round trip : %v", f.Err())
+		}
+	}
+}
+
+func TestVerifier_VerifyPaymentReport_BlockHeight(t *testing.T) {
+	tx, ancestors := loadVerifyFixture(t, "valid.json")
+	b, err := ancestors.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to marshal ancestors : %s", err)
+	}
+	payment := &spv.Payment{PaymentTx: tx, Ancestors: b}
+
+	tests := map[string]struct {
+		bhc       bc.BlockHeaderChain
+		expHeight uint64 // zero when the heights aren't known
+	}{
+		"heights unknown": {
+			bhc: &mockBlockHeaderChain{},
+		},
+		"heights looked up": {
+			bhc:       &mockBlockHeightChain{height: 700000},
+			expHeight: 700000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verifier, err := spv.NewPaymentVerifier(test.bhc, spv.NoVerifyScript())
+			if err != nil {
+				t.Fatalf("Failed to create verifier : %s", err)
+			}
+			report, err := verifier.VerifyPaymentReport(context.Background(), payment)
+			if err != nil {
+				t.Fatalf("Failed to verify payment : %s", err)
+			}
+			if !report.Valid {
+				t.Fatalf("Payment should be valid : %v", report.Err())
+			}
+
+			for _, txr := range report.Txs[1:] {
+				if test.expHeight == 0 {
+					if txr.BlockHeight != nil {
+						t.Fatalf("Ancestor %s should have no height : %d", txr.TxID,
+							*txr.BlockHeight)
+					}
+					continue
+				}
+				if txr.BlockHeight == nil || *txr.BlockHeight != test.expHeight {
+					t.Fatalf("Wrong height of ancestor %s : got %v, want %d", txr.TxID,
+						txr.BlockHeight, test.expHeight)
+				}
+			}
+		})
+	}
+}
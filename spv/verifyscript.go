@@ -0,0 +1,55 @@
+package spv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tokenized/go-bt"
+	"github.com/tokenized/go-bt/bscript/interpreter"
+)
+
+// verifyInputScript evaluates the unlocking script of input idx of tx against the
+// locking script of prevOut, the output it spends, returning ErrInvalidScript if it
+// fails. Signatures are checked against the BSV sighash, which commits to the satoshis
+// of prevOut and requires SIGHASH_FORKID.
+//
+// prevOut is evaluated under the Genesis script rules unless preGenesis is set.
+func verifyInputScript(tx *bt.Tx, idx int, prevOut *bt.Output, preGenesis bool) error {
+	opts := []interpreter.ExecutionOptionFunc{
+		interpreter.WithTx(tx, idx, prevOut),
+		interpreter.WithForkID(),
+	}
+	if preGenesis {
+		opts = append(opts, interpreter.WithP2SH())
+	} else {
+		opts = append(opts, interpreter.WithAfterGenesis())
+	}
+
+	if err := interpreter.NewEngine().Execute(opts...); err != nil {
+		return errors.Wrapf(ErrInvalidScript, "input %d of tx %s: %s", idx, tx.TxID(), err)
+	}
+
+	return nil
+}
+
+// isPreGenesis returns true if the ancestor a was mined before Genesis activated on the
+// network of the chain params. Unconfirmed ancestors are after Genesis, those anchored
+// by a merkle path are placed by its height and those anchored by a proof by the height
+// of its block, which can only be looked up in a bc.BlockHeightChain. Ancestors whose
+// height isn't known are treated as after Genesis, so their outputs are still evaluated
+// and invalid scripts fail.
+func (av *ancestryVerifier) isPreGenesis(ctx context.Context, a *Ancestor) bool {
+	if a.MerklePath != nil {
+		return a.MerklePath.BlockHeight < uint64(av.o.params.GenesisActivationHeight)
+	}
+	if a.Proof == nil {
+		return false
+	}
+
+	height, ok := av.proofBlockHeight(ctx, a.Proof)
+	if !ok {
+		return false
+	}
+
+	return height < av.o.params.GenesisActivationHeight
+}